@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/book"
+	"endtner.dev/nChess/internal/pgn"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+)
+
+/*
+	bookbuild creates a Polyglot book from PGN files:
+
+		bookbuild [-out book.bin] [-maxply 20] [-mingames 3] [-minscore 0.4] games.pgn...
+
+	Every game is replayed up to maxply, counting how often each move was played in each position and how it scored for
+	the side playing it. Moves played in fewer than mingames games or scoring below minscore are left out, the weight of
+	a move is the points it scored, so good and popular moves are played most often.
+*/
+
+type moveStats struct {
+	games  int
+	points int // Two for a win and one for a draw, so draws do not need fractions
+}
+
+type bookBuilder struct {
+	maxPly int
+	stats  map[uint64]map[uint16]*moveStats
+
+	games   int
+	skipped int
+}
+
+func main() {
+	out := flag.String("out", book.DefaultFile, "file the book is written to")
+	maxPly := flag.Int("maxply", 20, "number of plies of each game that go into the book")
+	minGames := flag.Int("mingames", 3, "minimum number of games a move has to be played in")
+	minScore := flag.Float64("minscore", 0.4, "minimum score of a move for the side playing it, from 0 to 1")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: bookbuild [flags] games.pgn...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	builder := &bookBuilder{maxPly: *maxPly, stats: make(map[uint64]map[uint16]*moveStats)}
+	for _, path := range flag.Args() {
+		if err := builder.addFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(1)
+		}
+	}
+
+	entries := builder.entries(*minGames, *minScore)
+	if err := writeBook(*out, entries); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *out, err)
+		os.Exit(1)
+	}
+
+	positions := 0
+	for i, entry := range entries {
+		if i == 0 || entry.Key != entries[i-1].Key {
+			positions++
+		}
+	}
+
+	fmt.Printf("Read %d games (%d skipped), wrote %d moves in %d positions to %s\n",
+		builder.games, builder.skipped, len(entries), positions, *out)
+}
+
+func (b *bookBuilder) addFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := pgn.NewReader(file)
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		var invalidGame *pgn.InvalidGameError
+		if errors.As(err, &invalidGame) {
+			fmt.Fprintf(os.Stderr, "%s: skipping game %d: %v\n", path, b.games+b.skipped+1, err)
+			b.skipped++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := b.addGame(game); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: skipping game %d: %v\n", path, b.games+b.skipped+1, err)
+			b.skipped++
+			continue
+		}
+		b.games++
+	}
+}
+
+func (b *bookBuilder) addGame(game *pgn.Game) error {
+	// Points for white, black gets the rest of the two points
+	var whitePoints int
+	switch game.Result {
+	case pgn.ResultWhiteWins:
+		whitePoints = 2
+	case pgn.ResultDraw:
+		whitePoints = 1
+	case pgn.ResultBlackWins:
+		whitePoints = 0
+	default:
+		return fmt.Errorf("unknown result %s", game.Result)
+	}
+
+	for ply, node := range game.Moves {
+		if ply >= b.maxPly {
+			break
+		}
+
+		p := game.Start
+		if ply > 0 {
+			p = game.Moves[ply-1].Position
+		}
+
+		points := whitePoints
+		if !p.WhiteToMove {
+			points = 2 - whitePoints
+		}
+		b.record(board.GetPolyglotKey(p), book.EncodeMove(node.Move), points)
+	}
+
+	return nil
+}
+
+func (b *bookBuilder) record(key uint64, move uint16, points int) {
+	positionStats, found := b.stats[key]
+	if !found {
+		positionStats = make(map[uint16]*moveStats)
+		b.stats[key] = positionStats
+	}
+
+	stats, found := positionStats[move]
+	if !found {
+		stats = &moveStats{}
+		positionStats[move] = stats
+	}
+
+	stats.games++
+	stats.points += points
+}
+
+// entries filters the collected moves and sorts them by key, and by weight within a position
+func (b *bookBuilder) entries(minGames int, minScore float64) []book.Entry {
+	var entries []book.Entry
+
+	for key, positionStats := range b.stats {
+		for move, stats := range positionStats {
+			score := float64(stats.points) / float64(2*stats.games)
+			if stats.games < minGames || score < minScore || stats.points == 0 {
+				continue
+			}
+
+			entries = append(entries, book.Entry{
+				Key:    key,
+				Move:   move,
+				Weight: uint16(min(stats.points, math.MaxUint16)),
+			})
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b book.Entry) int {
+		return cmp.Or(cmp.Compare(a.Key, b.Key), cmp.Compare(b.Weight, a.Weight), cmp.Compare(a.Move, b.Move))
+	})
+
+	return entries
+}
+
+func writeBook(path string, entries []book.Entry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	if err := book.Write(writer, entries); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
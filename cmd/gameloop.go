@@ -1,11 +1,51 @@
 package main
 
 import (
+	"endtner.dev/nChess/internal/book"
 	"endtner.dev/nChess/internal/game"
 	"endtner.dev/nChess/internal/game/players"
+	"flag"
+	"fmt"
+	"os"
 )
 
 func main() {
-	g := game.NewGame(players.HumanPlayer{}, players.EnginePlayer{})
+	pgnFile := flag.String("pgn", "", "file the finished game is written to")
+	timeControl := flag.String("tc", "", "time control in seconds like 300+2 or 40/5400+30, untimed if empty")
+	timeMode := flag.String("timemode", "fischer", "how the increment is given: fischer, bronstein or delay")
+	moveTime := flag.Duration("movetime", 0, "fixed time for every move, instead of a time control")
+	flag.Parse()
+
+	enginePlayer := players.NewEnginePlayer()
+
+	// A book next to the binary is used if there is one
+	if b, err := book.Open(book.DefaultFile); err == nil {
+		enginePlayer.Book = b
+	}
+
+	g := game.NewGame(players.HumanPlayer{}, enginePlayer)
+	g.PGNFile = *pgnFile
+
+	if *moveTime > 0 {
+		g.SetTimeControl(game.TimeControl{PerMove: *moveTime})
+	} else if *timeControl != "" {
+		tc, err := parseTimeControl(*timeControl, *timeMode)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		g.SetTimeControl(tc)
+	}
+
 	g.RunGameLoop()
 }
+
+func parseTimeControl(text, mode string) (game.TimeControl, error) {
+	modes := map[string]game.TimeMode{"fischer": game.Fischer, "bronstein": game.Bronstein, "delay": game.Delay}
+
+	timeMode, found := modes[mode]
+	if !found {
+		return game.TimeControl{}, fmt.Errorf("unknown time mode: %s", mode)
+	}
+	return game.ParseTimeControl(text, timeMode)
+}
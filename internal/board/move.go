@@ -2,45 +2,194 @@ package board
 
 import (
 	"fmt"
+	"strings"
 )
 
-type Move struct {
+/*
+	A Move is packed into 16 bits:
+	- Bits 0-5 hold the start square
+	- Bits 6-11 hold the target square
+	- Bits 12-15 hold the MoveFlag
+
+	Everything else the old move struct stored explicitly (rook square, en passant squares, color of the promoted piece)
+	follows from the squares and the flag. The zero value is the null move, as a1a1 is never a legal move.
+*/
+
+type Move uint16
+
+type MoveFlag uint16
+
+const (
+	QuietMove      MoveFlag = iota // Normal moves and captures
+	DoublePawnPush                 // Pawn moves two squares and creates an en passant square
+	CastleMove                     // King moves two squares, the rook jumps next to it
+	EnPassantMove                  // Pawn captures the pawn next to it
+
+	// Promotions store the promoted piece type relative to the rook in the lowest two bits
+	PromotionFlag MoveFlag = 0b1000
+)
+
+const NullMove Move = 0
+
+func EncodeMove(startIndex int, targetIndex int, flag MoveFlag) Move {
+	return Move(startIndex) | Move(targetIndex)<<6 | Move(flag)<<12
+}
+
+func PromotionMoveFlag(pieceType uint8) MoveFlag {
+	return PromotionFlag | MoveFlag(pieceType&0b00111-Rook)
+}
+
+func (m Move) From() int {
+	return int(m & 0b111111)
+}
+
+func (m Move) To() int {
+	return int(m >> 6 & 0b111111)
+}
+
+func (m Move) Flag() MoveFlag {
+	return MoveFlag(m >> 12)
+}
+
+func (m Move) IsCastle() bool {
+	return m.Flag() == CastleMove
+}
+
+func (m Move) IsEnPassant() bool {
+	return m.Flag() == EnPassantMove
+}
+
+func (m Move) IsDoublePawnPush() bool {
+	return m.Flag() == DoublePawnPush
+}
+
+func (m Move) IsPromotion() bool {
+	return m.Flag()&PromotionFlag != 0
+}
+
+// PromotionType returns the type of the promoted piece without a color, or 0 if the move is no promotion
+func (m Move) PromotionType() uint8 {
+	if !m.IsPromotion() {
+		return 0
+	}
+	return uint8(m.Flag()&0b11) + Rook
+}
+
+// EnPassantCaptureSquare is the square of the captured pawn, which is next to the start square
+func (m Move) EnPassantCaptureSquare() int {
+	return m.From()&^7 | m.To()&7
+}
+
+// EnPassantPassedSquare is the square a double pawn push skipped
+func (m Move) EnPassantPassedSquare() int {
+	return (m.From() + m.To()) / 2
+}
+
+func (m Move) RookStartingSquare() int {
+	isKingSideCastle := m.To()%8 == 6
+	if isKingSideCastle {
+		return m.To() + 1
+	}
+	return m.To() - 2
+}
+
+// CastlingRookTargetSquare returns the square next to the king the rook ends up on
+func (m Move) CastlingRookTargetSquare() int {
+	isKingSideCastle := m.To()%8 == 6
+	if isKingSideCastle {
+		return m.To() - 1
+	}
+	return m.To() + 1
+}
+
+// UnpackedMove spells out all information of a move, unused squares are -1
+type UnpackedMove struct {
 	StartIndex             int
 	TargetIndex            int
 	EnPassantCaptureSquare int
 	EnPassantPassedSquare  int
 	RookStartingSquare     int
-	PromotionPiece         uint8
+	PromotionPiece         uint8 // Promoted piece including its color
+}
+
+func (m Move) Unpack() UnpackedMove {
+	u := UnpackedMove{
+		StartIndex:             m.From(),
+		TargetIndex:            m.To(),
+		EnPassantCaptureSquare: -1,
+		EnPassantPassedSquare:  -1,
+		RookStartingSquare:     -1,
+	}
+
+	switch {
+	case m.IsCastle():
+		u.RookStartingSquare = m.RookStartingSquare()
+	case m.IsEnPassant():
+		u.EnPassantCaptureSquare = m.EnPassantCaptureSquare()
+	case m.IsDoublePawnPush():
+		u.EnPassantPassedSquare = m.EnPassantPassedSquare()
+	case m.IsPromotion():
+		// White pawns promote from the seventh rank, black pawns from the second one
+		color := White
+		if m.From()/8 == 1 {
+			color = Black
+		}
+		u.PromotionPiece = color | m.PromotionType()
+	}
+
+	return u
+}
+
+func (u UnpackedMove) Pack() Move {
+	flag := QuietMove
+
+	switch {
+	case u.RookStartingSquare != -1:
+		flag = CastleMove
+	case u.EnPassantCaptureSquare != -1:
+		flag = EnPassantMove
+	case u.EnPassantPassedSquare != -1:
+		flag = DoublePawnPush
+	case u.PromotionPiece != 0:
+		flag = PromotionMoveFlag(u.PromotionPiece)
+	}
+
+	return EncodeMove(u.StartIndex, u.TargetIndex, flag)
 }
 
-type OptionalParameter func(*Move)
+type OptionalParameter func(UnpackedMove) UnpackedMove
 
 func WithEnPassantCaptureSquare(square int) OptionalParameter {
-	return func(m *Move) {
+	return func(m UnpackedMove) UnpackedMove {
 		m.EnPassantCaptureSquare = square
+		return m
 	}
 }
 
 func WithEnPassantPassedSquare(square int) OptionalParameter {
-	return func(m *Move) {
+	return func(m UnpackedMove) UnpackedMove {
 		m.EnPassantPassedSquare = square
+		return m
 	}
 }
 
 func WithRookStartingSquare(square int) OptionalParameter {
-	return func(m *Move) {
+	return func(m UnpackedMove) UnpackedMove {
 		m.RookStartingSquare = square
+		return m
 	}
 }
 
 func WithPromotion(promotionPiece uint8) OptionalParameter {
-	return func(m *Move) {
+	return func(m UnpackedMove) UnpackedMove {
 		m.PromotionPiece = promotionPiece
+		return m
 	}
 }
 
+// NewMove builds a move the same way as the old move struct, the move generator uses the cheaper EncodeMove
 func NewMove(startIndex int, targetIndex int, optionalParameters ...OptionalParameter) Move {
-	m := Move{
+	m := UnpackedMove{
 		StartIndex:             startIndex,
 		TargetIndex:            targetIndex,
 		EnPassantCaptureSquare: -1,
@@ -50,24 +199,56 @@ func NewMove(startIndex int, targetIndex int, optionalParameters ...OptionalPara
 	}
 
 	for _, optionalParameter := range optionalParameters {
-		optionalParameter(&m)
+		m = optionalParameter(m)
 	}
 
-	return m
+	return m.Pack()
 }
 
+// MoveToString returns the move in UCI notation, promotions always use a lowercase piece
 func MoveToString(m Move) string {
-	return fmt.Sprintf("%s%s%s", IndexToSquare(m.StartIndex), IndexToSquare(m.TargetIndex), ToString(m.PromotionPiece))
+	return fmt.Sprintf("%s%s%s", IndexToSquare(m.From()), IndexToSquare(m.To()), strings.ToLower(ToString(m.PromotionType())))
 }
 
 // Making a move
 
-func (p *Position) MakeMove(m Move) *Position {
+/*
+	Moves are made and taken back in place. MakeMove pushes everything it can not recompute onto the undo stack of the
+	position, UnmakeMove pops it again, so the search never has to copy a position.
+*/
+
+type undoInfo struct {
+	move            Move
+	capturedPiece   uint8 // Piece removed from the target square, or the en passant square
+	castlingRights  uint8
+	enPassantSquare int
+	halfMoves       int
+	zobrist         uint64
+	result          Result
+}
+
+// MakeMoveCopy leaves the position untouched and returns a new one after the move, which links back to it through LastPos
+func (p *Position) MakeMoveCopy(m Move) *Position {
 	np := p.Copy()
 	np.LastPos = p
+	np.MakeMove(m)
+	return np
+}
+
+func (p *Position) MakeMove(m Move) {
+	from, to := m.From(), m.To()
+
+	undo := undoInfo{
+		move:            m,
+		castlingRights:  p.CastlingRights,
+		enPassantSquare: p.EnPassantSquare,
+		halfMoves:       p.HalfMoves,
+		zobrist:         p.Zobrist,
+		result:          p.Result,
+	}
 
 	// Zobrist: Switch color
-	np.Zobrist ^= ZobristColorToMove
+	p.Zobrist ^= ZobristColorToMove
 
 	// Set new castling availability
 	kingSideRookStart := 7
@@ -76,7 +257,7 @@ func (p *Position) MakeMove(m Move) *Position {
 	kingSideBitIndex := 3
 	queenSideBitIndex := 2
 
-	if !np.WhiteToMove {
+	if !p.WhiteToMove {
 		kingSideRookStart += 56
 		queenSideRookStart += 56
 		kingStart += 56
@@ -85,140 +266,227 @@ func (p *Position) MakeMove(m Move) *Position {
 	}
 
 	// Zobrist: Hash out old castling rights
-	np.Zobrist ^= ZobristCastlingRights[np.CastlingRights]
-
-	if m.StartIndex == kingSideRookStart {
-		np.CastlingRights = np.CastlingRights & ^(1 << kingSideBitIndex)
-	} else if m.StartIndex == queenSideRookStart {
-		np.CastlingRights = np.CastlingRights & ^(1 << queenSideBitIndex)
-	} else if m.StartIndex == kingStart {
-		np.CastlingRights = np.CastlingRights & ^(1 << kingSideBitIndex)
-		np.CastlingRights = np.CastlingRights & ^(1 << queenSideBitIndex)
+	p.Zobrist ^= ZobristCastlingRights[p.CastlingRights]
+
+	if from == kingSideRookStart {
+		p.CastlingRights = p.CastlingRights & ^(1 << kingSideBitIndex)
+	} else if from == queenSideRookStart {
+		p.CastlingRights = p.CastlingRights & ^(1 << queenSideBitIndex)
+	} else if from == kingStart {
+		p.CastlingRights = p.CastlingRights & ^(1 << kingSideBitIndex)
+		p.CastlingRights = p.CastlingRights & ^(1 << queenSideBitIndex)
 	}
 
 	// Zobrist: Hash in new castling rights
-	np.Zobrist ^= ZobristCastlingRights[np.CastlingRights]
+	p.Zobrist ^= ZobristCastlingRights[p.CastlingRights]
 
 	// Zobrist: Hashing out current EP Target Square
-	if np.EnPassantSquare != -1 {
-		np.Zobrist ^= ZobristEnPassant[np.EnPassantSquare]
+	if p.EnPassantSquare != -1 {
+		p.Zobrist ^= ZobristEnPassant[p.EnPassantSquare]
 	}
 
-	if m.EnPassantPassedSquare != -1 {
-		np.EnPassantSquare = m.EnPassantPassedSquare
+	if m.IsDoublePawnPush() {
+		p.EnPassantSquare = m.EnPassantPassedSquare()
 
 		// Zobrist: Hashing in new EP Target Square
-		np.Zobrist ^= ZobristEnPassant[np.EnPassantSquare]
-	} else if np.EnPassantSquare != -1 {
-		np.EnPassantSquare = -1
+		p.Zobrist ^= ZobristEnPassant[p.EnPassantSquare]
+	} else if p.EnPassantSquare != -1 {
+		p.EnPassantSquare = -1
 	}
 
 	// Increase half move if not a pawn move and not a capture
-	movedPieceType := np.Pieces[m.StartIndex] & 0b00111
-	targetPiece := np.Pieces[m.TargetIndex]
+	movedPieceType := p.Pieces[from] & 0b00111
+	targetPiece := p.Pieces[to]
 	if movedPieceType == Pawn || targetPiece != 0 {
-		np.HalfMoves = 0
+		p.HalfMoves = 0
 	} else {
-		np.HalfMoves += 1
+		p.HalfMoves += 1
 	}
 
 	// Increase the move number on blacks turns
-	if !np.WhiteToMove {
-		np.FullMoves += 1
+	if !p.WhiteToMove {
+		p.FullMoves += 1
 	}
 
 	// Actually move the piece on board
-	movedPiece := np.Pieces[m.StartIndex]
+	movedPiece := p.Pieces[from]
 
 	// Handle Castling
-	if m.RookStartingSquare != -1 {
+	if m.IsCastle() {
 
 		// Move king to target square
-		np.Pieces[m.StartIndex] = 0
-		np.Pieces[m.TargetIndex] = movedPiece
-		np.Bitboards[movedPiece] = (np.Bitboards[movedPiece] & ^(1 << m.StartIndex)) | (1 << m.TargetIndex)
+		p.Pieces[from] = 0
+		p.Pieces[to] = movedPiece
+		p.Bitboards[movedPiece] = (p.Bitboards[movedPiece] & ^(1 << from)) | (1 << to)
 
 		// Zobrist: Update moved king
-		np.Zobrist ^= ZobristTable[m.StartIndex][movedPiece]
-		np.Zobrist ^= ZobristTable[m.TargetIndex][movedPiece]
-
-		movedRook := np.Pieces[m.RookStartingSquare]
+		p.Zobrist ^= ZobristTable[from][movedPiece]
+		p.Zobrist ^= ZobristTable[to][movedPiece]
 
-		// Remove rook from pieces
-		np.Pieces[m.RookStartingSquare] = 0
+		rookStartSquare, rookTargetSquare := m.RookStartingSquare(), m.CastlingRookTargetSquare()
+		movedRook := p.Pieces[rookStartSquare]
 
-		kingSideTargetSquare := m.TargetIndex - 1
-		queenSideTargetSquare := m.TargetIndex + 1
-
-		isKingSideCastle := m.TargetIndex%8 == 6
-		if isKingSideCastle {
-			np.Pieces[kingSideTargetSquare] = movedRook
-			np.Bitboards[movedRook] = (np.Bitboards[movedRook] & ^(1 << m.RookStartingSquare)) | (1 << kingSideTargetSquare)
-
-			// Zobrist: Update moved rook
-			np.Zobrist ^= ZobristTable[m.RookStartingSquare][movedRook]
-			np.Zobrist ^= ZobristTable[kingSideTargetSquare][movedRook]
-		} else {
-			np.Pieces[queenSideTargetSquare] = movedRook
-			np.Bitboards[movedRook] = (np.Bitboards[movedRook] & ^(1 << m.RookStartingSquare)) | (1 << queenSideTargetSquare)
+		// Move rook next to the king
+		p.Pieces[rookStartSquare] = 0
+		p.Pieces[rookTargetSquare] = movedRook
+		p.Bitboards[movedRook] = (p.Bitboards[movedRook] & ^(1 << rookStartSquare)) | (1 << rookTargetSquare)
 
-			// Zobrist: Update moved rook
-			np.Zobrist ^= ZobristTable[m.RookStartingSquare][movedRook]
-			np.Zobrist ^= ZobristTable[queenSideTargetSquare][movedRook]
-		}
+		// Zobrist: Update moved rook
+		p.Zobrist ^= ZobristTable[rookStartSquare][movedRook]
+		p.Zobrist ^= ZobristTable[rookTargetSquare][movedRook]
 	} else {
 		// Remove piece from source square
-		np.Pieces[m.StartIndex] = 0
-		np.Bitboards[movedPiece] &= ^(1 << m.StartIndex)
+		p.Pieces[from] = 0
+		p.Bitboards[movedPiece] &= ^(1 << from)
 
 		// Possibly remove captured piece
-		capturedPiece := np.Pieces[m.TargetIndex]
+		capturedPiece := p.Pieces[to]
 		if capturedPiece != 0 && ((capturedPiece&0b11000)&(movedPiece&0b11000)) == 0 {
-			np.Pieces[m.TargetIndex] = 0
-			np.Bitboards[capturedPiece] &= ^(1 << m.TargetIndex)
+			p.Pieces[to] = 0
+			p.Bitboards[capturedPiece] &= ^(1 << to)
+			undo.capturedPiece = capturedPiece
 
 			// Zobrist: Update captured piece
-			np.Zobrist ^= ZobristTable[m.TargetIndex][capturedPiece]
+			p.Zobrist ^= ZobristTable[to][capturedPiece]
 		}
 
 		// Possibly remove EP captured piece
-		if m.EnPassantCaptureSquare != -1 {
-			epCapturedPiece := np.Pieces[m.EnPassantCaptureSquare]
+		if m.IsEnPassant() {
+			epCaptureSquare := m.EnPassantCaptureSquare()
+			epCapturedPiece := p.Pieces[epCaptureSquare]
 			if epCapturedPiece != 0 && ((epCapturedPiece&0b11000)&(movedPiece&0b11000)) == 0 {
-				np.Pieces[m.EnPassantCaptureSquare] = 0
-				np.Bitboards[epCapturedPiece] &= ^(1 << m.EnPassantCaptureSquare)
+				p.Pieces[epCaptureSquare] = 0
+				p.Bitboards[epCapturedPiece] &= ^(1 << epCaptureSquare)
+				undo.capturedPiece = epCapturedPiece
 
 				// Zobrist: Update EP Capture
-				np.Zobrist ^= ZobristTable[m.EnPassantCaptureSquare][epCapturedPiece]
+				p.Zobrist ^= ZobristTable[epCaptureSquare][epCapturedPiece]
 			}
 		}
 
 		// Add new piece on the target square
-		if m.PromotionPiece != 0 {
+		if m.IsPromotion() {
 			// Add newly promoted piece
-			np.Pieces[m.TargetIndex] = m.PromotionPiece
-			np.Bitboards[m.PromotionPiece] |= 1 << m.TargetIndex
+			promotionPiece := (movedPiece & 0b11000) | m.PromotionType()
+			p.Pieces[to] = promotionPiece
+			p.Bitboards[promotionPiece] |= 1 << to
 
 			// Zobrist: Update moved piece promotion
-			np.Zobrist ^= ZobristTable[m.StartIndex][movedPiece]
-			np.Zobrist ^= ZobristTable[m.TargetIndex][m.PromotionPiece]
+			p.Zobrist ^= ZobristTable[from][movedPiece]
+			p.Zobrist ^= ZobristTable[to][promotionPiece]
 		} else {
 			// Updating piece position
-			np.Pieces[m.TargetIndex] = movedPiece
-			np.Bitboards[movedPiece] |= 1 << m.TargetIndex
+			p.Pieces[to] = movedPiece
+			p.Bitboards[movedPiece] |= 1 << to
 
 			// Zobrist: Update moved piece
-			np.Zobrist ^= ZobristTable[m.StartIndex][movedPiece]
-			np.Zobrist ^= ZobristTable[m.TargetIndex][movedPiece]
+			p.Zobrist ^= ZobristTable[from][movedPiece]
+			p.Zobrist ^= ZobristTable[to][movedPiece]
 		}
 	}
 
+	p.undoStack = append(p.undoStack, undo)
+
 	// Switch around the color
-	np.OtherColorToMove()
+	p.OtherColorToMove()
+}
 
-	return np
+// MakeNullMove passes the turn to the opponent without moving, as used by null move pruning
+func (p *Position) MakeNullMove() {
+	p.undoStack = append(p.undoStack, undoInfo{
+		move:            NullMove,
+		castlingRights:  p.CastlingRights,
+		enPassantSquare: p.EnPassantSquare,
+		halfMoves:       p.HalfMoves,
+		zobrist:         p.Zobrist,
+		result:          p.Result,
+	})
+
+	// Zobrist: Switch color
+	p.Zobrist ^= ZobristColorToMove
+
+	// Zobrist: Hashing out current EP Target Square
+	if p.EnPassantSquare != -1 {
+		p.Zobrist ^= ZobristEnPassant[p.EnPassantSquare]
+		p.EnPassantSquare = -1
+	}
+
+	// Positions before a null move must not count as repetitions
+	p.HalfMoves = 0
+
+	if !p.WhiteToMove {
+		p.FullMoves += 1
+	}
+
+	p.OtherColorToMove()
 }
 
-func (p *Position) UnmakeMove() *Position {
-	return p.LastPos
+// UnmakeMove takes back the last move made with MakeMove or MakeNullMove
+func (p *Position) UnmakeMove() {
+	undo := p.undoStack[len(p.undoStack)-1]
+	p.undoStack = p.undoStack[:len(p.undoStack)-1]
+
+	m := undo.move
+	from, to := m.From(), m.To()
+	movedPiece := p.Pieces[to]
+
+	if m == NullMove {
+		// Nothing moved on the board
+	} else if m.IsCastle() {
+		// Move king and rook back to their starting squares
+		rookStartSquare, rookTargetSquare := m.RookStartingSquare(), m.CastlingRookTargetSquare()
+		movedRook := p.Pieces[rookTargetSquare]
+
+		p.Pieces[to] = 0
+		p.Pieces[from] = movedPiece
+		p.Bitboards[movedPiece] = (p.Bitboards[movedPiece] & ^(1 << to)) | (1 << from)
+
+		p.Pieces[rookTargetSquare] = 0
+		p.Pieces[rookStartSquare] = movedRook
+		p.Bitboards[movedRook] = (p.Bitboards[movedRook] & ^(1 << rookTargetSquare)) | (1 << rookStartSquare)
+	} else {
+		// Remove the piece from the target square, a promoted piece turns back into a pawn
+		p.Pieces[to] = 0
+		p.Bitboards[movedPiece] &= ^(1 << to)
+
+		if m.IsPromotion() {
+			movedPiece = (movedPiece & 0b11000) | Pawn
+		}
+
+		p.Pieces[from] = movedPiece
+		p.Bitboards[movedPiece] |= 1 << from
+
+		// Put back the captured piece
+		if undo.capturedPiece != 0 {
+			captureSquare := to
+			if m.IsEnPassant() {
+				captureSquare = m.EnPassantCaptureSquare()
+			}
+
+			p.Pieces[captureSquare] = undo.capturedPiece
+			p.Bitboards[undo.capturedPiece] |= 1 << captureSquare
+		}
+	}
+
+	p.CastlingRights = undo.castlingRights
+	p.EnPassantSquare = undo.enPassantSquare
+	p.HalfMoves = undo.halfMoves
+	p.Zobrist = undo.zobrist
+	p.Result = undo.result
+
+	// Switch back to the color that made the move
+	p.OtherColorToMove()
+
+	// Decrease the move number if black made the move
+	if !p.WhiteToMove {
+		p.FullMoves -= 1
+	}
+}
+
+// LastMove is the move that led to the position, NullMove if there is none or the turn was passed
+func (p *Position) LastMove() Move {
+	if len(p.undoStack) == 0 {
+		return NullMove
+	}
+	return p.undoStack[len(p.undoStack)-1].move
 }
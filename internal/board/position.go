@@ -2,13 +2,14 @@ package board
 
 import (
 	"math/bits"
+	"slices"
 )
 
 type Position struct {
 	// Bitboards are stored from bottom left to top right, meaning A1 to H8
 	// Bitboards are stored at the index of the piece they have
-	Bitboards []uint64
-	Pieces    []uint8
+	Bitboards [15]uint64
+	Pieces    [64]uint8
 
 	// Current player
 	WhiteToMove       bool
@@ -27,38 +28,22 @@ type Position struct {
 	HalfMoves       int
 	FullMoves       int
 
-	IsTerminal     bool
-	TerminalReason string
+	Result Result // Set once the rules end the game in this position
 
 	LastPos *Position
 
 	Zobrist uint64
+
+	/*
+		Moves made in place with MakeMove, that can be taken back with UnmakeMove. The stack also serves as the Zobrist
+		history of the game: Copy keeps it, so a position made with MakeMoveCopy knows every position along LastPos.
+	*/
+	undoStack []undoInfo
 }
 
 func (p *Position) Copy() *Position {
-	np := Position{
-		Bitboards:         make([]uint64, len(p.Bitboards)),
-		Pieces:            make([]uint8, len(p.Pieces)),
-		WhiteToMove:       p.WhiteToMove,
-		FriendlyColor:     p.FriendlyColor,
-		OpponentColor:     p.OpponentColor,
-		FriendlyIndex:     p.FriendlyIndex,
-		OpponentIndex:     p.OpponentIndex,
-		FriendlyKingIndex: p.FriendlyKingIndex,
-		OpponentKingIndex: p.OpponentKingIndex,
-		PawnOffset:        p.PawnOffset,
-		PromotionRank:     p.PromotionRank,
-		CastlingRights:    p.CastlingRights,
-		EnPassantSquare:   p.EnPassantSquare,
-		HalfMoves:         p.HalfMoves,
-		FullMoves:         p.FullMoves,
-		IsTerminal:        p.IsTerminal,
-		TerminalReason:    p.TerminalReason,
-		LastPos:           p.LastPos,
-		Zobrist:           p.Zobrist,
-	}
-	copy(np.Bitboards, p.Bitboards)
-	copy(np.Pieces, p.Pieces)
+	np := *p
+	np.undoStack = slices.Clone(p.undoStack)
 	return &np
 }
 
@@ -77,30 +62,30 @@ func (p *Position) OtherColorToMove() {
 }
 
 func (p *Position) UpdateTerminalState(hasLegalMoves, isInCheck bool) {
-	p.IsTerminal = false
-	p.TerminalReason = ""
-
-	if !hasLegalMoves {
-		if isInCheck {
-			p.IsTerminal = true
-			if p.WhiteToMove {
-				p.TerminalReason = "Black wins by checkmate"
-			} else {
-				p.TerminalReason = "White wins by checkmate"
-			}
-		} else {
-			p.IsTerminal = true
-			p.TerminalReason = "Draw by stalemate"
-		}
-	} else if p.IsInsufficientMaterial() {
-		p.IsTerminal = true
-		p.TerminalReason = "Draw by insufficient material"
-	} else if p.IsFiftyMoveRule() {
-		p.IsTerminal = true
-		p.TerminalReason = "Draw by fifty-move rule"
+	p.Result = Result{}
+
+	switch {
+	case !hasLegalMoves && isInCheck:
+		p.Result = Win(!p.WhiteToMove, Checkmate)
+	case !hasLegalMoves:
+		p.Result = DrawBy(Stalemate)
+	case p.IsInsufficientMaterial():
+		p.Result = DrawBy(InsufficientMaterial)
+	case p.IsFivefoldRepetition():
+		p.Result = DrawBy(FivefoldRepetition)
+	case p.IsSeventyFiveMoveRule():
+		p.Result = DrawBy(SeventyFiveMoveRule)
+	case p.IsThreefoldRepetition():
+		p.Result = DrawBy(ThreefoldRepetition)
+	case p.IsFiftyMoveRule():
+		p.Result = DrawBy(FiftyMoveRule)
 	}
 }
 
+func (p *Position) IsTerminal() bool {
+	return p.Result.IsOver()
+}
+
 func (p *Position) IsInsufficientMaterial() bool {
 	whitePieces := p.Bitboards[White|Knight] | p.Bitboards[White|Bishop] | p.Bitboards[White|Rook] | p.Bitboards[White|Queen] | p.Bitboards[White|Pawn]
 	blackPieces := p.Bitboards[Black|Knight] | p.Bitboards[Black|Bishop] | p.Bitboards[Black|Rook] | p.Bitboards[Black|Queen] | p.Bitboards[Black|Pawn]
@@ -121,6 +106,54 @@ func (p *Position) IsInsufficientMaterial() bool {
 	return false
 }
 
+// HasMatingMaterial reports whether the side could ever checkmate, which takes a pawn, a rook, a queen or two minor pieces
+func (p *Position) HasMatingMaterial(white bool) bool {
+	color := White
+	if !white {
+		color = Black
+	}
+
+	if p.Bitboards[color|Pawn]|p.Bitboards[color|Rook]|p.Bitboards[color|Queen] != 0 {
+		return true
+	}
+	return bits.OnesCount64(p.Bitboards[color|Knight]|p.Bitboards[color|Bishop]) >= 2
+}
+
 func (p *Position) IsFiftyMoveRule() bool {
 	return p.HalfMoves >= 100 // 50 full moves = 100 half moves
 }
+
+func (p *Position) IsSeventyFiveMoveRule() bool {
+	return p.HalfMoves >= 150
+}
+
+// IsRepetition reports if the current position occurred before, the search treats this as a draw already
+func (p *Position) IsRepetition() bool {
+	return p.countRepetitions(1) >= 1
+}
+
+func (p *Position) IsThreefoldRepetition() bool {
+	return p.countRepetitions(2) >= 2
+}
+
+func (p *Position) IsFivefoldRepetition() bool {
+	return p.countRepetitions(4) >= 4
+}
+
+// countRepetitions counts earlier occurrences of the current position, but stops as soon as limit is reached
+func (p *Position) countRepetitions(limit int) int {
+	count := 0
+
+	// Only positions since the last capture or pawn move can repeat, and only every second one has the same side to move
+	oldest := max(len(p.undoStack)-p.HalfMoves, 0)
+	for i := len(p.undoStack) - 2; i >= oldest; i -= 2 {
+		if p.undoStack[i].zobrist == p.Zobrist {
+			count++
+			if count >= limit {
+				break
+			}
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,99 @@
+package board
+
+/*
+	A game ends with a winner, or a draw, and the reason it ended. The rules decide most of them from the position alone,
+	the others come from outside of it: a clock, a player who resigns or agrees to a draw, or someone adjudicating.
+*/
+
+type Winner uint8
+
+const (
+	WinnerNone Winner = iota // The game is not over
+	WinnerWhite
+	WinnerBlack
+	WinnerDraw
+)
+
+type Reason uint8
+
+const (
+	NoReason Reason = iota
+	Checkmate
+	Stalemate
+	InsufficientMaterial
+	FiftyMoveRule
+	SeventyFiveMoveRule
+	ThreefoldRepetition
+	FivefoldRepetition
+	Timeout // A draw if the opponent could not have checkmated anymore
+	Resignation
+	Agreement
+	Adjudication
+)
+
+type Result struct {
+	Winner Winner
+	Reason Reason
+}
+
+// Win is the result of a game the given side won
+func Win(white bool, reason Reason) Result {
+	if white {
+		return Result{Winner: WinnerWhite, Reason: reason}
+	}
+	return Result{Winner: WinnerBlack, Reason: reason}
+}
+
+func DrawBy(reason Reason) Result {
+	return Result{Winner: WinnerDraw, Reason: reason}
+}
+
+func (r Result) IsOver() bool {
+	return r.Winner != WinnerNone
+}
+
+func (r Result) String() string {
+	switch r.Winner {
+	case WinnerWhite:
+		return "White wins " + r.Reason.String()
+	case WinnerBlack:
+		return "Black wins " + r.Reason.String()
+	case WinnerDraw:
+		if r.Reason == Timeout {
+			return "Draw by timeout vs insufficient material"
+		}
+		return "Draw " + r.Reason.String()
+	default:
+		return "Game in progress"
+	}
+}
+
+// String describes the reason as it follows the winner, like "by checkmate" or "on time"
+func (r Reason) String() string {
+	switch r {
+	case Checkmate:
+		return "by checkmate"
+	case Stalemate:
+		return "by stalemate"
+	case InsufficientMaterial:
+		return "by insufficient material"
+	case FiftyMoveRule:
+		return "by fifty-move rule"
+	case SeventyFiveMoveRule:
+		return "by seventy-five-move rule"
+	case ThreefoldRepetition:
+		return "by threefold repetition"
+	case FivefoldRepetition:
+		return "by fivefold repetition"
+	case Timeout:
+		return "on time"
+	case Resignation:
+		return "by resignation"
+	case Agreement:
+		return "by agreement"
+	case Adjudication:
+		return "by adjudication"
+	default:
+		return ""
+	}
+}
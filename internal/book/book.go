@@ -0,0 +1,218 @@
+package book
+
+import (
+	"encoding/binary"
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/engine"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"slices"
+	"time"
+)
+
+const DefaultFile = "book.bin" // Used by the game loop, if it exists
+
+/*
+	A Polyglot book is a list of 16 byte entries, sorted by the Polyglot key of the position and stored big endian:
+
+	- key (8 bytes)
+	- move (2 bytes): to square (bits 0-5), from square (bits 6-11), promotion piece (bits 12-14, knight to queen as 1-4)
+	- weight (2 bytes): how often the move should be played, relative to the other moves of the position
+	- learn (4 bytes): unused
+
+	Castling is stored as the king capturing its own rook, e1h1 instead of e1g1.
+*/
+
+type Entry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+type Book struct {
+	entries []Entry
+	random  *rand.Rand
+}
+
+// BookMove is a move of the book that is legal in the position it was looked up for
+type BookMove struct {
+	Move   board.Move
+	Weight int
+}
+
+const entrySize = 16
+
+func Open(path string) (*Book, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+func Read(r io.Reader) (*Book, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%entrySize != 0 {
+		return nil, fmt.Errorf("book size %d is not a multiple of %d bytes", len(data), entrySize)
+	}
+
+	b := &Book{
+		entries: make([]Entry, 0, len(data)/entrySize),
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for offset := 0; offset < len(data); offset += entrySize {
+		b.entries = append(b.entries, Entry{
+			Key:    binary.BigEndian.Uint64(data[offset:]),
+			Move:   binary.BigEndian.Uint16(data[offset+8:]),
+			Weight: binary.BigEndian.Uint16(data[offset+10:]),
+			Learn:  binary.BigEndian.Uint32(data[offset+12:]),
+		})
+	}
+
+	// Lookups depend on the order, which not every book tool keeps
+	slices.SortStableFunc(b.entries, func(a, b Entry) int {
+		switch {
+		case a.Key < b.Key:
+			return -1
+		case a.Key > b.Key:
+			return 1
+		}
+		return 0
+	})
+
+	return b, nil
+}
+
+func (b *Book) Len() int {
+	return len(b.entries)
+}
+
+// Moves returns the book moves of the position, moves that are not legal there are left out
+func (b *Book) Moves(p *board.Position) []BookMove {
+	key := board.GetPolyglotKey(p)
+	first, _ := slices.BinarySearchFunc(b.entries, key, func(e Entry, key uint64) int {
+		switch {
+		case e.Key < key:
+			return -1
+		case e.Key > key:
+			return 1
+		}
+		return 0
+	})
+
+	var moves []BookMove
+	for _, entry := range b.entries[first:] {
+		if entry.Key != key {
+			break
+		}
+		if m, ok := DecodeMove(p, entry.Move); ok {
+			moves = append(moves, BookMove{Move: m, Weight: int(entry.Weight)})
+		}
+	}
+	return moves
+}
+
+// PickMove chooses one of the book moves at random, moves with a higher weight are played more often
+func (b *Book) PickMove(p *board.Position) (board.Move, bool) {
+	moves := b.Moves(p)
+
+	totalWeight := 0
+	for _, m := range moves {
+		totalWeight += m.Weight
+	}
+	if totalWeight == 0 {
+		return board.NullMove, false
+	}
+
+	choice := b.random.Intn(totalWeight)
+	for _, m := range moves {
+		if choice < m.Weight {
+			return m.Move, true
+		}
+		choice -= m.Weight
+	}
+
+	return board.NullMove, false
+}
+
+// Write stores entries in the Polyglot format, they have to be sorted by key already
+func Write(w io.Writer, entries []Entry) error {
+	buffer := make([]byte, entrySize)
+	for _, entry := range entries {
+		binary.BigEndian.PutUint64(buffer, entry.Key)
+		binary.BigEndian.PutUint16(buffer[8:], entry.Move)
+		binary.BigEndian.PutUint16(buffer[10:], entry.Weight)
+		binary.BigEndian.PutUint32(buffer[12:], entry.Learn)
+
+		if _, err := w.Write(buffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMove finds the legal move of the position a Polyglot move stands for
+func DecodeMove(p *board.Position, polyglotMove uint16) (board.Move, bool) {
+	to := int(polyglotMove & 0b111111)
+	from := int(polyglotMove>>6) & 0b111111
+	promotion := int(polyglotMove>>12) & 0b111
+
+	// Castling is stored as the king capturing its own rook
+	if p.Pieces[from]&0b00111 == board.King && p.Pieces[to] == p.FriendlyColor|board.Rook {
+		if to > from {
+			to = from + 2
+		} else {
+			to = from - 2
+		}
+	}
+
+	promotionTypes := [5]uint8{0, board.Knight, board.Bishop, board.Rook, board.Queen}
+	if promotion >= len(promotionTypes) {
+		return board.NullMove, false
+	}
+
+	for _, m := range engine.LegalMoves(p) {
+		if m.From() != from || m.To() != to {
+			continue
+		}
+		if promotion == 0 && !m.IsPromotion() || promotion != 0 && m.IsPromotion() && m.PromotionType() == promotionTypes[promotion] {
+			return m, true
+		}
+	}
+
+	return board.NullMove, false
+}
+
+// EncodeMove is the inverse of DecodeMove
+func EncodeMove(m board.Move) uint16 {
+	from, to := m.From(), m.To()
+
+	if m.IsCastle() {
+		to = m.RookStartingSquare()
+	}
+
+	var promotion uint16
+	if m.IsPromotion() {
+		switch m.PromotionType() {
+		case board.Knight:
+			promotion = 1
+		case board.Bishop:
+			promotion = 2
+		case board.Rook:
+			promotion = 3
+		case board.Queen:
+			promotion = 4
+		}
+	}
+
+	return uint16(to) | uint16(from)<<6 | promotion<<12
+}
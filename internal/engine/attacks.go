@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"endtner.dev/nChess/internal/board"
+)
+
+func ColorPieces(p *board.Position, color uint8) uint64 {
+	return p.Bitboards[color|board.Pawn] | p.Bitboards[color|board.Knight] | p.Bitboards[color|board.Bishop] | p.Bitboards[color|board.Rook] | p.Bitboards[color|board.Queen] | p.Bitboards[color|board.King]
+}
+
+// AttackersTo returns the pieces of both colors attacking the square, sliders are blocked by the given occupancy
+func AttackersTo(p *board.Position, square int, occupancy uint64) uint64 {
+	orthogonalSliders := p.Bitboards[board.White|board.Rook] | p.Bitboards[board.Black|board.Rook] | p.Bitboards[board.White|board.Queen] | p.Bitboards[board.Black|board.Queen]
+	diagonalSliders := p.Bitboards[board.White|board.Bishop] | p.Bitboards[board.Black|board.Bishop] | p.Bitboards[board.White|board.Queen] | p.Bitboards[board.Black|board.Queen]
+
+	// A pawn attacks the square, if a pawn of the other color on the square would attack the pawn
+	return ComputedPawnAttacks[1][square]&p.Bitboards[board.White|board.Pawn] |
+		ComputedPawnAttacks[0][square]&p.Bitboards[board.Black|board.Pawn] |
+		ComputedKnightMoves[square]&(p.Bitboards[board.White|board.Knight]|p.Bitboards[board.Black|board.Knight]) |
+		ComputedKingMoves[square]&(p.Bitboards[board.White|board.King]|p.Bitboards[board.Black|board.King]) |
+		PGetRookMoves(square, occupancy)&orthogonalSliders |
+		PGetBishopMoves(square, occupancy)&diagonalSliders
+}
+
+// IsSquareAttacked checks if any piece of the given color attacks the square
+func IsSquareAttacked(p *board.Position, square int, byColor uint8) bool {
+	occupancy := ColorPieces(p, board.White) | ColorPieces(p, board.Black)
+	return AttackersTo(p, square, occupancy)&ColorPieces(p, byColor) != 0
+}
+
+func IsInCheck(p *board.Position) bool {
+	return IsSquareAttacked(p, p.FriendlyKingIndex, p.OpponentColor)
+}
@@ -105,14 +105,15 @@ func PieceValue(piece uint8) int {
 	}
 }
 
-func Evaluate(p *board.Position) float64 {
+// Evaluate returns the static evaluation in centipawns from the view of the side to move
+func Evaluate(p *board.Position) int {
 	score := 0
 	gamePhase := calculateGamePhase(p)
 
 	score += evaluateMaterial(p)
 	score += evaluatePieceSquareTables(p, gamePhase)
 
-	return float64(score) / 100
+	return score
 }
 
 func evaluateMaterial(p *board.Position) int {
@@ -4,434 +4,490 @@ import (
 	"endtner.dev/nChess/internal/board"
 	"fmt"
 	"math/bits"
-	"time"
 )
 
 /*
-	MoveGenerator is now responsible for generating all Bitboards. THIS CODE DOES NOT RUN IN PARALLEL!
+	MoveGenerator is now responsible for generating all Bitboards. A generation keeps all of its state in a moveGenerator
+	on the stack and writes the moves into a buffer supplied by the caller, so it neither allocates nor touches any shared
+	state. Any number of goroutines may generate moves at the same time.
 
-	When parallelized, other generators will override these variables for a given generator, so everything crashes.
-	I tried instancing the generator per class, that created so much overhead that the performance increase was mitigated.
+	The time spent in the single phases can be measured by building with the 'profile' tag, see profile.go.
 */
 
 /*
 	Generators
 */
 
-var TotalTimePrecompute time.Duration
-var TotalTimeKingGeneration time.Duration
-var TotalTimePawnGeneration time.Duration
-var TotalTimeSlidingGeneration time.Duration
-var TotalTimeKnightGeneration time.Duration
+const MaxMoves = 218 // Maximum possible moves in a chess position
 
+// MoveBuffer is large enough to hold the moves of any position
+type MoveBuffer [MaxMoves]board.Move
+
+type GenerationMode byte
+
+const (
+	AllMoves     GenerationMode = iota
+	CapturesOnly                // Captures, en passant and promotions, as needed by the quiescence search
+	QuietsOnly                  // Every move CapturesOnly leaves out, including castling
+)
+
+// LegalMoves allocates a new buffer on every call, the search uses GenerateMoves with buffers it keeps around
 func LegalMoves(p *board.Position) []board.Move {
-	pseudoLegalMoves := make([]board.Move, 218) // Maximum possible moves in a chess position is 218
-	index := 0
+	return GenerateMoves(p, AllMoves, new(MoveBuffer))
+}
+
+type moveGenerator struct {
+	p     *board.Position
+	mode  GenerationMode
+	moves *MoveBuffer
+	index int
+
+	friendlyColor uint8
+	opponentColor uint8
+	friendlyIndex int
+	opponentIndex int
+
+	friendlyPieces uint64
+	opponentPieces uint64
+	allPieces      uint64
+	targetMask     uint64 // Squares pieces are allowed to move to in this generation mode
+
+	friendlyPawns             uint64
+	friendlyKnights           uint64
+	friendlyRooks             uint64
+	friendlyOrthogonalSliders uint64
+	friendlyDiagonalSliders   uint64
+	friendlyKingBitboard      uint64
+	friendlyKingIndex         int
+
+	opponentOrthogonalSliders uint64
+	opponentDiagonalSliders   uint64
+	opponentAttacks           uint64
+
+	inCheck         bool
+	inDoubleCheck   bool
+	friendlyPinRays uint64
+	validMoveMask   uint64
+}
 
-	startPrecompute := time.Now()
+// GenerateMoves writes the legal moves of the position into moves and returns the filled part of it
+func GenerateMoves(p *board.Position, mode GenerationMode, moves *MoveBuffer) []board.Move {
+	startPrecompute := profileStart()
 
 	friendlyColor := p.FriendlyColor
 	opponentColor := p.OpponentColor
 
-	friendlyIndex := p.FriendlyIndex
-	opponentIndex := p.OpponentIndex
+	g := moveGenerator{
+		p:             p,
+		mode:          mode,
+		moves:         moves,
+		friendlyColor: friendlyColor,
+		opponentColor: opponentColor,
+		friendlyIndex: p.FriendlyIndex,
+		opponentIndex: p.OpponentIndex,
+	}
+
+	g.friendlyPieces = p.Bitboards[friendlyColor|board.Pawn] | p.Bitboards[friendlyColor|board.Knight] | p.Bitboards[friendlyColor|board.Rook] | p.Bitboards[friendlyColor|board.Bishop] | p.Bitboards[friendlyColor|board.Queen] | p.Bitboards[friendlyColor|board.King]
+	g.opponentPieces = p.Bitboards[opponentColor|board.Pawn] | p.Bitboards[opponentColor|board.Knight] | p.Bitboards[opponentColor|board.Rook] | p.Bitboards[opponentColor|board.Bishop] | p.Bitboards[opponentColor|board.Queen] | p.Bitboards[opponentColor|board.King]
+	g.allPieces = g.friendlyPieces | g.opponentPieces
+
+	g.targetMask = ^g.friendlyPieces
+	switch mode {
+	case CapturesOnly:
+		g.targetMask = g.opponentPieces
+	case QuietsOnly:
+		g.targetMask = ^g.allPieces
+	}
+
+	g.friendlyPawns = p.Bitboards[friendlyColor|board.Pawn]
+	g.friendlyKnights = p.Bitboards[friendlyColor|board.Knight]
+	g.friendlyRooks = p.Bitboards[friendlyColor|board.Rook]
+	g.friendlyOrthogonalSliders = g.friendlyRooks | p.Bitboards[friendlyColor|board.Queen]
+	g.friendlyDiagonalSliders = p.Bitboards[friendlyColor|board.Bishop] | p.Bitboards[friendlyColor|board.Queen]
+	g.friendlyKingBitboard = p.Bitboards[friendlyColor|board.King]
+	g.friendlyKingIndex = p.FriendlyKingIndex
 
-	friendlyPieces := p.Bitboards[friendlyColor|board.Pawn] | p.Bitboards[friendlyColor|board.Knight] | p.Bitboards[friendlyColor|board.Rook] | p.Bitboards[friendlyColor|board.Bishop] | p.Bitboards[friendlyColor|board.Queen] | p.Bitboards[friendlyColor|board.King]
-	opponentPieces := p.Bitboards[opponentColor|board.Pawn] | p.Bitboards[opponentColor|board.Knight] | p.Bitboards[opponentColor|board.Rook] | p.Bitboards[opponentColor|board.Bishop] | p.Bitboards[opponentColor|board.Queen] | p.Bitboards[opponentColor|board.King]
-	allPieces := friendlyPieces | opponentPieces
+	g.opponentOrthogonalSliders = p.Bitboards[opponentColor|board.Rook] | p.Bitboards[opponentColor|board.Queen]
+	g.opponentDiagonalSliders = p.Bitboards[opponentColor|board.Bishop] | p.Bitboards[opponentColor|board.Queen]
 
-	friendlyPawns := p.Bitboards[friendlyColor|board.Pawn]
-	friendlyKnights := p.Bitboards[friendlyColor|board.Knight]
-	friendlyRooks := p.Bitboards[friendlyColor|board.Rook]
-	friendlyOrthogonalSliders := friendlyRooks | p.Bitboards[friendlyColor|board.Queen]
-	friendlyDiagonalSliders := p.Bitboards[friendlyColor|board.Bishop] | p.Bitboards[friendlyColor|board.Queen]
-	friendlyKingBitboard := p.Bitboards[friendlyColor|board.King]
-	friendlyKingIndex := p.FriendlyKingIndex
+	g.updateSlideAttacks(g.opponentOrthogonalSliders, true)
+	g.updateSlideAttacks(g.opponentDiagonalSliders, false)
+	g.computeAttackData()
 
-	opponentOrthogonalSliders := p.Bitboards[opponentColor|board.Rook] | p.Bitboards[opponentColor|board.Queen]
-	opponentDiagonalSliders := p.Bitboards[opponentColor|board.Bishop] | p.Bitboards[opponentColor|board.Queen]
+	/*
+		Creating pseudo-legal moves, then filtering out illegal moves
+	*/
+	profileStop(PhasePrecompute, startPrecompute)
 
-	opponentSlidingAttacks := uint64(0)
-	var UpdateSlideAttacks = func(pieces uint64, orthogonal bool) {
-		blockers := allPieces & ^(1 << friendlyKingIndex)
+	startKingMoves := profileStart()
+	g.kingMoves()
+	profileStop(PhaseKingGeneration, startKingMoves)
 
-		for pieces != 0 {
-			opponentSlidingAttacks |= PGetSliderMoves(bits.TrailingZeros64(pieces), blockers, orthogonal)
-			pieces &= pieces - 1
+	// Can directly return king moves if we are in multi check
+	if g.inDoubleCheck {
+		if mode == AllMoves {
+			p.UpdateTerminalState(g.index != 0, true)
 		}
+		return moves[:g.index]
 	}
-	UpdateSlideAttacks(opponentOrthogonalSliders, true)
-	UpdateSlideAttacks(opponentDiagonalSliders, false)
-	opponentAttacks := opponentSlidingAttacks
 
-	inCheck := false
-	inDoubleCheck := false
+	if g.inCheck {
+		g.friendlyOrthogonalSliders &= ^g.friendlyPinRays
+		g.friendlyDiagonalSliders &= ^g.friendlyPinRays
+	}
 
-	friendlyPinRays := uint64(0)
-	validMoveMask := uint64(0)
+	startPawnMoves := profileStart()
+	g.pawnMoves()
+	profileStop(PhasePawnGeneration, startPawnMoves)
 
-	var ComputeAttackData = func() {
+	startSlidingMoves := profileStart()
+	g.slidingMoves()
+	profileStop(PhaseSlidingGeneration, startSlidingMoves)
 
-		// TODO: Check if there are no queens, if yes check if there are no rooks/bishops to loop over less directions
+	startKnightMoves := profileStart()
+	g.friendlyKnights &= ^g.friendlyPinRays // Knights can never move if pinned
+	g.knightMoves()
+	profileStop(PhaseKnightGeneration, startKnightMoves)
 
-		for offsetIndex, offset := range DirectionalOffsets {
-			isDiagonal := offsetIndex > 3
+	// Without quiet moves we cannot tell if there are legal moves left
+	if mode == AllMoves {
+		p.UpdateTerminalState(g.index != 0, (g.opponentAttacks>>g.friendlyKingIndex)&1 != 0)
+	}
 
-			sliders := opponentOrthogonalSliders
-			if isDiagonal {
-				sliders = opponentDiagonalSliders
-			}
+	return moves[:g.index]
+}
 
-			// TODO: Can skip offset if there are no sliders in that direction
+func (g *moveGenerator) add(m board.Move) {
+	g.moves[g.index] = m
+	g.index++
+}
 
-			rayMask := uint64(0)
-			isFriendlyPieceAlongRay := false
+func (g *moveGenerator) updateSlideAttacks(pieces uint64, orthogonal bool) {
+	blockers := g.allPieces & ^(1 << g.friendlyKingIndex)
 
-			for distance := range DistanceToEdge[friendlyKingIndex][offsetIndex] {
-				squareIndex := friendlyKingIndex + (offset * (distance + 1))
-				p := p.Pieces[squareIndex]
+	for pieces != 0 {
+		g.opponentAttacks |= PGetSliderMoves(bits.TrailingZeros64(pieces), blockers, orthogonal)
+		pieces &= pieces - 1
+	}
+}
 
-				rayMask |= 1 << squareIndex
+func (g *moveGenerator) computeAttackData() {
+	p := g.p
 
-				if p == 0 {
-					continue
-				}
+	// TODO: Check if there are no queens, if yes check if there are no rooks/bishops to loop over less directions
 
-				if (p & 0b11000) == friendlyColor {
-					// Break if it is the second friendly piece we encounter
-					if !isFriendlyPieceAlongRay {
-						isFriendlyPieceAlongRay = true
-					} else {
-						break
-					}
-
-				} else {
-					// Check if piece is one of the current sliders
-					if (sliders & (1 << squareIndex)) != 0 {
-						if isFriendlyPieceAlongRay {
-							friendlyPinRays |= rayMask // There is a friendly blocking piece, so it is a pin
-						} else {
-							validMoveMask |= rayMask // There is no friendly blocking piece, so it is a check
-							inDoubleCheck = inCheck
-							inCheck = true
-						}
-					}
-					break // We either discovered a pin or a check OR the enemy piece at that index is blocking any attacks
-				}
-			}
+	for offsetIndex, offset := range DirectionalOffsets {
+		isDiagonal := offsetIndex > 3
 
-			// Only king can move if we are in double check
-			if inDoubleCheck {
-				break
-			}
+		sliders := g.opponentOrthogonalSliders
+		if isDiagonal {
+			sliders = g.opponentDiagonalSliders
 		}
 
-		opponentKnights := p.Bitboards[opponentColor|board.Knight]
-		for opponentKnights != 0 {
-			knightIndex := bits.TrailingZeros64(opponentKnights)
-			knightAttacks := ComputedKnightMoves[knightIndex]
+		// TODO: Can skip offset if there are no sliders in that direction
 
-			if (knightAttacks & friendlyKingBitboard) != 0 {
-				inDoubleCheck = inCheck
-				inCheck = true
-				validMoveMask |= 1 << knightIndex
-			}
+		rayMask := uint64(0)
+		isFriendlyPieceAlongRay := false
 
-			opponentAttacks |= knightAttacks
-			opponentKnights &= opponentKnights - 1
-		}
+		for distance := range DistanceToEdge[g.friendlyKingIndex][offsetIndex] {
+			squareIndex := g.friendlyKingIndex + (offset * (distance + 1))
+			p := p.Pieces[squareIndex]
 
-		opponentPawns := p.Bitboards[opponentColor|board.Pawn]
-		for opponentPawns != 0 {
-			pawnIndex := bits.TrailingZeros64(opponentPawns)
-			pawnAttacks := ComputedPawnAttacks[opponentIndex][pawnIndex]
+			rayMask |= 1 << squareIndex
 
-			if (pawnAttacks & friendlyKingBitboard) != 0 {
-				inDoubleCheck = inCheck
-				inCheck = true
-				validMoveMask |= 1 << pawnIndex
+			if p == 0 {
+				continue
 			}
 
-			opponentAttacks |= pawnAttacks
-			opponentPawns &= opponentPawns - 1
-		}
+			if (p & 0b11000) == g.friendlyColor {
+				// Break if it is the second friendly piece we encounter
+				if !isFriendlyPieceAlongRay {
+					isFriendlyPieceAlongRay = true
+				} else {
+					break
+				}
 
-		opponentAttacks |= ComputedKingMoves[bits.TrailingZeros64(p.Bitboards[opponentColor|board.King])]
+			} else {
+				// Check if piece is one of the current sliders
+				if (sliders & (1 << squareIndex)) != 0 {
+					if isFriendlyPieceAlongRay {
+						g.friendlyPinRays |= rayMask // There is a friendly blocking piece, so it is a pin
+					} else {
+						g.validMoveMask |= rayMask // There is no friendly blocking piece, so it is a check
+						g.inDoubleCheck = g.inCheck
+						g.inCheck = true
+					}
+				}
+				break // We either discovered a pin or a check OR the enemy piece at that index is blocking any attacks
+			}
+		}
 
-		if !inCheck {
-			validMoveMask = ^uint64(0)
+		// Only king can move if we are in double check
+		if g.inDoubleCheck {
+			break
 		}
 	}
-	ComputeAttackData()
 
-	/*
-		Validation functions
-	*/
+	opponentKnights := p.Bitboards[g.opponentColor|board.Knight]
+	for opponentKnights != 0 {
+		knightIndex := bits.TrailingZeros64(opponentKnights)
+		knightAttacks := ComputedKnightMoves[knightIndex]
 
-	var IsEnPassantMovePinned = func(startSquare, targetSquare, epCaptureSquare int) bool {
-		epBlockers := uint64((1 << startSquare) | (1 << targetSquare) | (1 << epCaptureSquare))
-		maskedBlockers := (allPieces | epBlockers) & ^(allPieces & epBlockers)
-
-		if opponentOrthogonalSliders != 0 {
-			rookAttacks := PGetRookMoves(friendlyKingIndex, maskedBlockers)
-			return (rookAttacks & opponentOrthogonalSliders) != 0
+		if (knightAttacks & g.friendlyKingBitboard) != 0 {
+			g.inDoubleCheck = g.inCheck
+			g.inCheck = true
+			g.validMoveMask |= 1 << knightIndex
 		}
 
-		if opponentDiagonalSliders != 0 {
-			bishopAttacks := PGetBishopMoves(friendlyKingIndex, maskedBlockers)
-			return (bishopAttacks & opponentDiagonalSliders) != 0
-		}
-
-		return false
+		g.opponentAttacks |= knightAttacks
+		opponentKnights &= opponentKnights - 1
 	}
 
-	/*
-		Generator functions
-	*/
-	var KingMoves = func() {
-		kingMoveMask := ComputedKingMoves[friendlyKingIndex] & ^friendlyPieces & ^opponentAttacks
-		for kingMoveMask != 0 {
-			pseudoLegalMoves[index] = board.NewMove(friendlyKingIndex, bits.TrailingZeros64(kingMoveMask))
-			index++
-
-			kingMoveMask &= kingMoveMask - 1
-		}
+	opponentPawns := p.Bitboards[g.opponentColor|board.Pawn]
+	for opponentPawns != 0 {
+		pawnIndex := bits.TrailingZeros64(opponentPawns)
+		pawnAttacks := ComputedPawnAttacks[g.opponentIndex][pawnIndex]
 
-		initialKingIndex := 4
-		if friendlyColor != board.White {
-			initialKingIndex = 60
+		if (pawnAttacks & g.friendlyKingBitboard) != 0 {
+			g.inDoubleCheck = g.inCheck
+			g.inCheck = true
+			g.validMoveMask |= 1 << pawnIndex
 		}
 
-		// King is not on its original square, will not be allowed to castle
-		if inCheck || friendlyKingIndex != initialKingIndex {
-			return
-		}
+		g.opponentAttacks |= pawnAttacks
+		opponentPawns &= opponentPawns - 1
+	}
 
-		kingSideAllowed := p.CastlingRights&0b1000 != 0
-		queenSideAllowed := p.CastlingRights&0b0100 != 0
+	g.opponentAttacks |= ComputedKingMoves[bits.TrailingZeros64(p.Bitboards[g.opponentColor|board.King])]
 
-		var kingSideEmptyMask uint64 = 0b1100000
-		var queenSideEmptyMask uint64 = 0b1110
+	if !g.inCheck {
+		g.validMoveMask = ^uint64(0)
+	}
+}
 
-		kingSideRookIndex := 7
-		queenSideRookIndex := 0
+func (g *moveGenerator) isEnPassantMovePinned(startSquare, targetSquare, epCaptureSquare int) bool {
+	epBlockers := uint64((1 << startSquare) | (1 << targetSquare) | (1 << epCaptureSquare))
+	maskedBlockers := (g.allPieces | epBlockers) & ^(g.allPieces & epBlockers)
 
-		var kingSideAttackMask uint64 = 1<<friendlyKingIndex | 1<<(friendlyKingIndex+1) | 1<<(friendlyKingIndex+2)
-		var queenSideAttackMask uint64 = 1<<friendlyKingIndex | 1<<(friendlyKingIndex-1) | 1<<(friendlyKingIndex-2)
+	if g.opponentOrthogonalSliders != 0 {
+		rookAttacks := PGetRookMoves(g.friendlyKingIndex, maskedBlockers)
+		return (rookAttacks & g.opponentOrthogonalSliders) != 0
+	}
 
-		if !p.WhiteToMove {
-			kingSideAllowed = p.CastlingRights&0b0010 != 0
-			queenSideAllowed = p.CastlingRights&0b0001 != 0
+	if g.opponentDiagonalSliders != 0 {
+		bishopAttacks := PGetBishopMoves(g.friendlyKingIndex, maskedBlockers)
+		return (bishopAttacks & g.opponentDiagonalSliders) != 0
+	}
 
-			kingSideEmptyMask <<= 56
-			queenSideEmptyMask <<= 56
+	return false
+}
 
-			kingSideRookIndex += 56
-			queenSideRookIndex += 56
-		}
+func (g *moveGenerator) kingMoves() {
+	p := g.p
 
-		if kingSideAllowed &&
-			(kingSideAttackMask&opponentAttacks) == 0 && // King does not start or pass through attacked field
-			(kingSideEmptyMask&allPieces) == 0 && // All fields are empty
-			board.IsIndexBitSet(kingSideRookIndex, friendlyRooks) { // There is a rook on its field
+	kingMoveMask := ComputedKingMoves[g.friendlyKingIndex] & g.targetMask & ^g.opponentAttacks
+	for kingMoveMask != 0 {
+		g.add(board.EncodeMove(g.friendlyKingIndex, bits.TrailingZeros64(kingMoveMask), board.QuietMove))
 
-			pseudoLegalMoves[index] = board.NewMove(friendlyKingIndex, friendlyKingIndex+2, board.WithRookStartingSquare(kingSideRookIndex))
-			index++
-		}
+		kingMoveMask &= kingMoveMask - 1
+	}
 
-		if queenSideAllowed &&
-			(queenSideAttackMask&opponentAttacks) == 0 &&
-			(queenSideEmptyMask&allPieces) == 0 &&
-			board.IsIndexBitSet(queenSideRookIndex, friendlyRooks) {
+	initialKingIndex := 4
+	if g.friendlyColor != board.White {
+		initialKingIndex = 60
+	}
 
-			pseudoLegalMoves[index] = board.NewMove(friendlyKingIndex, friendlyKingIndex-2, board.WithRookStartingSquare(queenSideRookIndex))
-			index++
-		}
+	// King is not on its original square, will not be allowed to castle
+	if g.inCheck || g.friendlyKingIndex != initialKingIndex || g.mode == CapturesOnly {
+		return
 	}
 
-	var PawnMoves = func() {
-		for friendlyPawns != 0 {
-			// Get index of LSB
-			pieceIndex := bits.TrailingZeros64(friendlyPawns)
-			targetIndex := pieceIndex + p.PawnOffset
+	kingSideAllowed := p.CastlingRights&0b1000 != 0
+	queenSideAllowed := p.CastlingRights&0b0100 != 0
 
-			// Continue if target index is out of bounds, just go to the next iteration
-			if targetIndex < 0 || targetIndex > 63 {
-				friendlyPawns &= friendlyPawns - 1
-				continue
-			}
+	var kingSideEmptyMask uint64 = 0b1100000
+	var queenSideEmptyMask uint64 = 0b1110
 
-			// Check if the pawn is on starting square
-			isBaseRank := pieceIndex/8 == 1
-			isPromotionRank := pieceIndex/8 == 6
+	kingSideRookIndex := 7
+	queenSideRookIndex := 0
 
-			if !p.WhiteToMove {
-				isBaseRank, isPromotionRank = isPromotionRank, isBaseRank
-			}
+	var kingSideAttackMask uint64 = 1<<g.friendlyKingIndex | 1<<(g.friendlyKingIndex+1) | 1<<(g.friendlyKingIndex+2)
+	var queenSideAttackMask uint64 = 1<<g.friendlyKingIndex | 1<<(g.friendlyKingIndex-1) | 1<<(g.friendlyKingIndex-2)
 
-			// Handling normal moves
-			validMoves := ComputedPawnMoves[friendlyIndex][pieceIndex] & ^allPieces
+	if !p.WhiteToMove {
+		kingSideAllowed = p.CastlingRights&0b0010 != 0
+		queenSideAllowed = p.CastlingRights&0b0001 != 0
 
-			// Adding double pawn pushes
-			if isBaseRank && validMoves != 0 {
-				validMoves |= ComputedPawnMoves[friendlyIndex][pieceIndex+p.PawnOffset] & ^allPieces
-			}
+		kingSideEmptyMask <<= 56
+		queenSideEmptyMask <<= 56
 
-			// Generate all attacks
-			attackMask := ComputedPawnAttacks[p.FriendlyIndex][pieceIndex]
-			validAttacks := attackMask & opponentPieces
+		kingSideRookIndex += 56
+		queenSideRookIndex += 56
+	}
 
-			// Generate en passant attacks
-			validPawnMoveMask := validMoveMask
-			if p.EnPassantSquare != -1 {
-				if 1<<(p.EnPassantSquare-p.PawnOffset)&validMoveMask != 0 {
-					validPawnMoveMask |= 1 << p.EnPassantSquare
-				}
-				validAttacks |= attackMask & (1 << p.EnPassantSquare)
-			}
+	if kingSideAllowed &&
+		(kingSideAttackMask&g.opponentAttacks) == 0 && // King does not start or pass through attacked field
+		(kingSideEmptyMask&g.allPieces) == 0 && // All fields are empty
+		board.IsIndexBitSet(kingSideRookIndex, g.friendlyRooks) { // There is a rook on its field
 
-			validMoves |= validAttacks
+		g.add(board.EncodeMove(g.friendlyKingIndex, g.friendlyKingIndex+2, board.CastleMove))
+	}
 
-			// Handle pins
-			if (friendlyPinRays & (1 << pieceIndex)) != 0 {
-				validMoves &= AlignmentMask[pieceIndex][friendlyKingIndex]
-			}
+	if queenSideAllowed &&
+		(queenSideAttackMask&g.opponentAttacks) == 0 &&
+		(queenSideEmptyMask&g.allPieces) == 0 &&
+		board.IsIndexBitSet(queenSideRookIndex, g.friendlyRooks) {
 
-			// Filter out non-protecting moves
-			validMoves &= validPawnMoveMask
-
-			// Writing moves
-			for validMoves != 0 {
-				moveTargetIndex := bits.TrailingZeros64(validMoves)
-
-				if isPromotionRank {
-					pseudoLegalMoves[index] = board.NewMove(pieceIndex, moveTargetIndex, board.WithPromotion(p.FriendlyColor|board.Knight))
-					index++
-					pseudoLegalMoves[index] = board.NewMove(pieceIndex, moveTargetIndex, board.WithPromotion(p.FriendlyColor|board.Bishop))
-					index++
-					pseudoLegalMoves[index] = board.NewMove(pieceIndex, moveTargetIndex, board.WithPromotion(p.FriendlyColor|board.Queen))
-					index++
-					pseudoLegalMoves[index] = board.NewMove(pieceIndex, moveTargetIndex, board.WithPromotion(p.FriendlyColor|board.Rook))
-					index++
-				} else {
-					if moveTargetIndex == p.EnPassantSquare {
-						if !IsEnPassantMovePinned(pieceIndex, moveTargetIndex, moveTargetIndex-p.PawnOffset) {
-							pseudoLegalMoves[index] = board.NewMove(pieceIndex, moveTargetIndex, board.WithEnPassantCaptureSquare(moveTargetIndex-p.PawnOffset))
-							index++
-						}
-					} else if moveTargetIndex == targetIndex+p.PawnOffset {
-						pseudoLegalMoves[index] = board.NewMove(pieceIndex, moveTargetIndex, board.WithEnPassantPassedSquare(moveTargetIndex-p.PawnOffset))
-						index++
-					} else {
-						pseudoLegalMoves[index] = board.NewMove(pieceIndex, moveTargetIndex)
-						index++
-					}
-				}
+		g.add(board.EncodeMove(g.friendlyKingIndex, g.friendlyKingIndex-2, board.CastleMove))
+	}
+}
 
-				validMoves &= validMoves - 1
-			}
+func (g *moveGenerator) pawnMoves() {
+	p := g.p
+
+	for g.friendlyPawns != 0 {
+		// Get index of LSB
+		pieceIndex := bits.TrailingZeros64(g.friendlyPawns)
+		targetIndex := pieceIndex + p.PawnOffset
 
-			// Remove LSB of bitboard
-			friendlyPawns &= friendlyPawns - 1
+		// Continue if target index is out of bounds, just go to the next iteration
+		if targetIndex < 0 || targetIndex > 63 {
+			g.friendlyPawns &= g.friendlyPawns - 1
+			continue
 		}
-	}
 
-	var SlidingMoves = func() {
-		for friendlyOrthogonalSliders|friendlyDiagonalSliders != 0 {
-			pieceIndex := bits.TrailingZeros64(friendlyOrthogonalSliders | friendlyDiagonalSliders)
+		// Check if the pawn is on starting square
+		isBaseRank := pieceIndex/8 == 1
+		isPromotionRank := pieceIndex/8 == 6
 
-			if friendlyOrthogonalSliders&(1<<pieceIndex) != 0 {
-				sliderMoves := PGetRookMoves(pieceIndex, allPieces) & ^friendlyPieces & validMoveMask
+		if !p.WhiteToMove {
+			isBaseRank, isPromotionRank = isPromotionRank, isBaseRank
+		}
 
-				if (friendlyPinRays & (1 << pieceIndex)) != 0 {
-					sliderMoves &= AlignmentMask[pieceIndex][friendlyKingIndex]
-				}
+		// Handling normal moves
+		validMoves := ComputedPawnMoves[g.friendlyIndex][pieceIndex] & ^g.allPieces
 
-				for sliderMoves != 0 {
-					targetIndex := bits.TrailingZeros64(sliderMoves)
+		// Adding double pawn pushes
+		if isBaseRank && validMoves != 0 {
+			validMoves |= ComputedPawnMoves[g.friendlyIndex][pieceIndex+p.PawnOffset] & ^g.allPieces
+		}
 
-					pseudoLegalMoves[index] = board.NewMove(pieceIndex, targetIndex)
-					index++
+		// Pushes only count as tactical moves if they promote
+		if (g.mode == CapturesOnly && !isPromotionRank) || (g.mode == QuietsOnly && isPromotionRank) {
+			validMoves = 0
+		}
 
-					sliderMoves &= sliderMoves - 1
-				}
+		// Generate all attacks
+		attackMask := ComputedPawnAttacks[p.FriendlyIndex][pieceIndex]
+		validAttacks := attackMask & g.opponentPieces
 
-				friendlyOrthogonalSliders &= friendlyOrthogonalSliders - 1
+		// Generate en passant attacks
+		validPawnMoveMask := g.validMoveMask
+		if p.EnPassantSquare != -1 {
+			if 1<<(p.EnPassantSquare-p.PawnOffset)&g.validMoveMask != 0 {
+				validPawnMoveMask |= 1 << p.EnPassantSquare
 			}
-			if friendlyDiagonalSliders&(1<<pieceIndex) != 0 {
-				sliderMoves := PGetBishopMoves(pieceIndex, allPieces) & ^friendlyPieces & validMoveMask
+			validAttacks |= attackMask & (1 << p.EnPassantSquare)
+		}
 
-				if (friendlyPinRays & (1 << pieceIndex)) != 0 {
-					sliderMoves &= AlignmentMask[pieceIndex][friendlyKingIndex]
-				}
+		if g.mode == QuietsOnly {
+			validAttacks = 0
+		}
 
-				for sliderMoves != 0 {
-					targetIndex := bits.TrailingZeros64(sliderMoves)
+		validMoves |= validAttacks
 
-					pseudoLegalMoves[index] = board.NewMove(pieceIndex, targetIndex)
-					index++
+		// Handle pins
+		if (g.friendlyPinRays & (1 << pieceIndex)) != 0 {
+			validMoves &= AlignmentMask[pieceIndex][g.friendlyKingIndex]
+		}
 
-					sliderMoves &= sliderMoves - 1
+		// Filter out non-protecting moves
+		validMoves &= validPawnMoveMask
+
+		// Writing moves
+		for validMoves != 0 {
+			moveTargetIndex := bits.TrailingZeros64(validMoves)
+
+			if isPromotionRank {
+				g.add(board.EncodeMove(pieceIndex, moveTargetIndex, board.PromotionMoveFlag(board.Knight)))
+				g.add(board.EncodeMove(pieceIndex, moveTargetIndex, board.PromotionMoveFlag(board.Bishop)))
+				g.add(board.EncodeMove(pieceIndex, moveTargetIndex, board.PromotionMoveFlag(board.Queen)))
+				g.add(board.EncodeMove(pieceIndex, moveTargetIndex, board.PromotionMoveFlag(board.Rook)))
+			} else {
+				if moveTargetIndex == p.EnPassantSquare {
+					if !g.isEnPassantMovePinned(pieceIndex, moveTargetIndex, moveTargetIndex-p.PawnOffset) {
+						g.add(board.EncodeMove(pieceIndex, moveTargetIndex, board.EnPassantMove))
+					}
+				} else if moveTargetIndex == targetIndex+p.PawnOffset {
+					g.add(board.EncodeMove(pieceIndex, moveTargetIndex, board.DoublePawnPush))
+				} else {
+					g.add(board.EncodeMove(pieceIndex, moveTargetIndex, board.QuietMove))
 				}
-
-				friendlyDiagonalSliders &= friendlyDiagonalSliders - 1
 			}
+
+			validMoves &= validMoves - 1
 		}
+
+		// Remove LSB of bitboard
+		g.friendlyPawns &= g.friendlyPawns - 1
 	}
+}
+
+func (g *moveGenerator) slidingMoves() {
+	for g.friendlyOrthogonalSliders|g.friendlyDiagonalSliders != 0 {
+		pieceIndex := bits.TrailingZeros64(g.friendlyOrthogonalSliders | g.friendlyDiagonalSliders)
+
+		if g.friendlyOrthogonalSliders&(1<<pieceIndex) != 0 {
+			sliderMoves := PGetRookMoves(pieceIndex, g.allPieces) & g.targetMask & g.validMoveMask
 
-	var KnightMoves = func() {
-		for friendlyKnights != 0 {
-			pieceIndex := bits.TrailingZeros64(friendlyKnights)
+			if (g.friendlyPinRays & (1 << pieceIndex)) != 0 {
+				sliderMoves &= AlignmentMask[pieceIndex][g.friendlyKingIndex]
+			}
 
-			validMoves := ComputedKnightMoves[pieceIndex] & ^friendlyPieces & validMoveMask
+			for sliderMoves != 0 {
+				targetIndex := bits.TrailingZeros64(sliderMoves)
 
-			for validMoves != 0 {
-				pseudoLegalMoves[index] = board.NewMove(pieceIndex, bits.TrailingZeros64(validMoves))
-				index++
+				g.add(board.EncodeMove(pieceIndex, targetIndex, board.QuietMove))
 
-				validMoves &= validMoves - 1
+				sliderMoves &= sliderMoves - 1
 			}
 
-			friendlyKnights &= friendlyKnights - 1
+			g.friendlyOrthogonalSliders &= g.friendlyOrthogonalSliders - 1
 		}
-	}
+		if g.friendlyDiagonalSliders&(1<<pieceIndex) != 0 {
+			sliderMoves := PGetBishopMoves(pieceIndex, g.allPieces) & g.targetMask & g.validMoveMask
 
-	/*
-		Creating pseudo-legal moves, then filtering out illegal moves
-	*/
-	TotalTimePrecompute += time.Since(startPrecompute)
+			if (g.friendlyPinRays & (1 << pieceIndex)) != 0 {
+				sliderMoves &= AlignmentMask[pieceIndex][g.friendlyKingIndex]
+			}
 
-	startKingMoves := time.Now()
-	KingMoves()
-	TotalTimeKingGeneration += time.Since(startKingMoves)
+			for sliderMoves != 0 {
+				targetIndex := bits.TrailingZeros64(sliderMoves)
 
-	// Can directly return king moves if we are in multi check
-	if inDoubleCheck {
-		return pseudoLegalMoves[:index]
-	}
+				g.add(board.EncodeMove(pieceIndex, targetIndex, board.QuietMove))
 
-	if inCheck {
-		friendlyOrthogonalSliders &= ^friendlyPinRays
-		friendlyDiagonalSliders &= ^friendlyPinRays
+				sliderMoves &= sliderMoves - 1
+			}
+
+			g.friendlyDiagonalSliders &= g.friendlyDiagonalSliders - 1
+		}
 	}
+}
 
-	startPawnMoves := time.Now()
-	PawnMoves()
-	TotalTimePawnGeneration += time.Since(startPawnMoves)
+func (g *moveGenerator) knightMoves() {
+	for g.friendlyKnights != 0 {
+		pieceIndex := bits.TrailingZeros64(g.friendlyKnights)
 
-	//fmt.Println(formatter.UnicodeBoardWithBorders(formatter.ToUnicodeBoard(map[uint64]string{friendlyPinRays: "P"})))
-	startSlidingMoves := time.Now()
-	SlidingMoves()
-	TotalTimeSlidingGeneration += time.Since(startSlidingMoves)
+		validMoves := ComputedKnightMoves[pieceIndex] & g.targetMask & g.validMoveMask
 
-	startKnightMoves := time.Now()
-	friendlyKnights &= ^friendlyPinRays // Knights can never move if pinned
-	KnightMoves()
-	TotalTimeKnightGeneration += time.Since(startKnightMoves)
+		for validMoves != 0 {
+			g.add(board.EncodeMove(pieceIndex, bits.TrailingZeros64(validMoves), board.QuietMove))
 
-	p.UpdateTerminalState(len(pseudoLegalMoves) != 0, (opponentAttacks>>friendlyKingIndex)&1 != 0)
+			validMoves &= validMoves - 1
+		}
 
-	return pseudoLegalMoves[:index]
+		g.friendlyKnights &= g.friendlyKnights - 1
+	}
 }
 
 /*
@@ -440,25 +496,25 @@ func LegalMoves(p *board.Position) []board.Move {
 
 type TTablePerft map[uint64]int64
 
-var tt = make([]TTablePerft, 10)
-var isInitialized = func() bool {
-	for i := range len(tt) {
-		tt[i] = make(TTablePerft)
-	}
-
-	return true
-}()
-
 func Perft(p *board.Position, ply int, maxPly int) int64 {
 	/*
-		Perft Testing Utility
+		Perft Testing Utility, every call uses its own tables and buffers so perft may run in parallel
 	*/
 
+	tt := make([]TTablePerft, max(ply+1, 0))
+	for i := range tt {
+		tt[i] = make(TTablePerft)
+	}
+
+	return perft(p, ply, maxPly, tt, make([]MoveBuffer, max(ply+1, 0)))
+}
+
+func perft(p *board.Position, ply int, maxPly int, tt []TTablePerft, buffers []MoveBuffer) int64 {
 	if ply == 0 {
 		return 1
 	}
 
-	legalMoves := LegalMoves(p)
+	legalMoves := GenerateMoves(p, AllMoves, &buffers[ply])
 	var totalNodes int64 = 0
 
 	if nodes, found := tt[ply][p.Zobrist]; found {
@@ -471,9 +527,10 @@ func Perft(p *board.Position, ply int, maxPly int) int64 {
 	}
 
 	for _, m := range legalMoves {
-		np := p.MakeMove(m)
+		p.MakeMove(m)
+		subNodes := perft(p, ply-1, maxPly, tt, buffers)
+		p.UnmakeMove()
 
-		subNodes := Perft(np, ply-1, maxPly)
 		totalNodes += subNodes
 
 		if ply == maxPly {
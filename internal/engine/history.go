@@ -0,0 +1,88 @@
+package engine
+
+import "endtner.dev/nChess/internal/board"
+
+const (
+	MaxHistory      = 16384 // History scores always stay within [-MaxHistory, MaxHistory]
+	MaxHistoryBonus = 1200
+
+	MaxSearchedQuiets = 64 // Quiet moves of a node that get a malus at most, when a later one causes a cutoff
+)
+
+/*
+	The history heuristics remember which quiet moves caused beta cutoffs, to try them early in other nodes as well:
+
+	- Butterfly history: scores by from and to square, independent of the position
+	- Counter moves: the quiet move that last refuted a move, by the square the move went to
+	- Continuation history: scores of a quiet move by the piece and target square of the move before it
+
+	A quiet move causing a cutoff gets a bonus, the quiet moves searched before it the same malus. Updates are damped by
+	the current value (gravity), so scores converge inside the bounds and old knowledge slowly fades.
+*/
+
+type MoveHistory struct {
+	butterfly    [64][64]int
+	counterMoves [15][64]board.Move
+	continuation [15][64][15][64]int16
+}
+
+func (h *MoveHistory) Clear() {
+	*h = MoveHistory{}
+}
+
+// Score of a quiet move, given the move that was played before it
+func (h *MoveHistory) Score(p *board.Position, m board.Move) int {
+	from, to := m.From(), m.To()
+	score := h.butterfly[from][to]
+
+	if previous := p.LastMove(); previous != board.NullMove {
+		previousPiece := p.Pieces[previous.To()]
+		score += int(h.continuation[previousPiece][previous.To()][p.Pieces[from]][to])
+	}
+
+	return score
+}
+
+// CounterMove is the quiet move that last refuted the move played before, NullMove if there is none
+func (h *MoveHistory) CounterMove(p *board.Position) board.Move {
+	previous := p.LastMove()
+	if previous == board.NullMove {
+		return board.NullMove
+	}
+	return h.counterMoves[p.Pieces[previous.To()]][previous.To()]
+}
+
+// Update rewards the quiet move that caused a beta cutoff and punishes the quiet moves that were searched before it,
+// searchedQuiets has to end with the cutoff move
+func (h *MoveHistory) Update(p *board.Position, cutoffMove board.Move, searchedQuiets []board.Move, depth int) {
+	bonus := min(depth*depth*16, MaxHistoryBonus)
+
+	previous := p.LastMove()
+	var previousPiece uint8
+	if previous != board.NullMove {
+		previousPiece = p.Pieces[previous.To()]
+		h.counterMoves[previousPiece][previous.To()] = cutoffMove
+	}
+
+	for _, m := range searchedQuiets {
+		moveBonus := -bonus
+		if m == cutoffMove {
+			moveBonus = bonus
+		}
+
+		from, to := m.From(), m.To()
+		applyGravity(&h.butterfly[from][to], moveBonus)
+
+		if previous != board.NullMove {
+			entry := &h.continuation[previousPiece][previous.To()][p.Pieces[from]][to]
+			value := int(*entry)
+			applyGravity(&value, moveBonus)
+			*entry = int16(value)
+		}
+	}
+}
+
+// applyGravity adds the bonus, the closer the entry already is to the bound in that direction the less it changes
+func applyGravity(entry *int, bonus int) {
+	*entry += bonus - *entry*abs(bonus)/MaxHistory
+}
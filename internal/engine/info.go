@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"time"
+)
+
+// SearchInfo describes the state of the search after a completed iteration
+type SearchInfo struct {
+	MultiPV  int // 1-based index of the reported line
+	Depth    int
+	SelDepth int
+	Score    int // Centipawns or a mate score, see IsMateScore
+	Nodes    int64
+	Time     time.Duration
+	HashFull int // Permill of the transposition table in use
+	PV       []board.Move
+}
+
+func (i SearchInfo) NodesPerSecond() int64 {
+	if i.Time <= 0 {
+		return 0
+	}
+	return i.Nodes * int64(time.Second) / int64(i.Time)
+}
+
+// CurrentMoveInfo describes the root move that is being searched right now
+type CurrentMoveInfo struct {
+	Depth  int
+	Move   board.Move
+	Number int // 1-based position of the move in the root move list
+}
+
+// Root moves are only reported after this delay, so short searches do not flood the GUI
+const CurrentMoveReportDelay = time.Second
@@ -0,0 +1,142 @@
+package engine
+
+import "endtner.dev/nChess/internal/board"
+
+/*
+	IsLegal checks a move that was not generated for the position, like the hash move or a killer move, which may come
+	from an entirely different position. The move is first checked to be possible on the board, then made to see if it
+	leaves the own king in check.
+*/
+
+func IsLegal(p *board.Position, m board.Move) bool {
+	if m == board.NullMove {
+		return false
+	}
+
+	from, to := m.From(), m.To()
+	piece := p.Pieces[from]
+	target := p.Pieces[to]
+
+	if piece == 0 || piece&0b11000 != p.FriendlyColor {
+		return false
+	}
+	if target != 0 && (target&0b11000 == p.FriendlyColor || target&0b00111 == board.King) {
+		return false
+	}
+
+	occupancy := ColorPieces(p, board.White) | ColorPieces(p, board.Black)
+	pieceType := piece & 0b00111
+
+	switch {
+	case m.IsCastle():
+		// Castling checks the attacked squares itself, so it does not need to be made
+		return pieceType == board.King && isCastlingPossible(p, m, occupancy)
+	case pieceType == board.Pawn:
+		if !isPawnMovePossible(p, m, target) {
+			return false
+		}
+	default:
+		if m.Flag() != board.QuietMove || pieceAttacks(pieceType, from, occupancy)&(1<<to) == 0 {
+			return false
+		}
+	}
+
+	p.MakeMove(m)
+	legal := !IsSquareAttacked(p, p.OpponentKingIndex, p.FriendlyColor)
+	p.UnmakeMove()
+
+	return legal
+}
+
+func pieceAttacks(pieceType uint8, square int, occupancy uint64) uint64 {
+	switch pieceType {
+	case board.Knight:
+		return ComputedKnightMoves[square]
+	case board.Bishop:
+		return PGetBishopMoves(square, occupancy)
+	case board.Rook:
+		return PGetRookMoves(square, occupancy)
+	case board.Queen:
+		return PGetBishopMoves(square, occupancy) | PGetRookMoves(square, occupancy)
+	case board.King:
+		return ComputedKingMoves[square]
+	default:
+		return 0
+	}
+}
+
+func isPawnMovePossible(p *board.Position, m board.Move, target uint8) bool {
+	from, to := m.From(), m.To()
+
+	// Pawns have to promote exactly when reaching the last rank
+	isLastRank := to/8 == 7
+	if !p.WhiteToMove {
+		isLastRank = to/8 == 0
+	}
+	if isLastRank != m.IsPromotion() {
+		return false
+	}
+
+	isCapture := ComputedPawnAttacks[p.FriendlyIndex][from]&(1<<to) != 0
+
+	switch {
+	case m.IsEnPassant():
+		return isCapture && to == p.EnPassantSquare
+	case m.IsDoublePawnPush():
+		isBaseRank := from/8 == 1
+		if !p.WhiteToMove {
+			isBaseRank = from/8 == 6
+		}
+		return isBaseRank && to == from+2*p.PawnOffset && p.Pieces[from+p.PawnOffset] == 0 && target == 0
+	case m.Flag() == board.QuietMove || m.IsPromotion():
+		return (to == from+p.PawnOffset && target == 0) || (isCapture && target != 0)
+	default:
+		return false
+	}
+}
+
+func isCastlingPossible(p *board.Position, m board.Move, occupancy uint64) bool {
+	from, to := m.From(), m.To()
+
+	initialKingIndex := 4
+	if !p.WhiteToMove {
+		initialKingIndex = 60
+	}
+	if from != initialKingIndex || (to != from+2 && to != from-2) {
+		return false
+	}
+
+	isKingSide := to > from
+
+	// Castling rights are set like KQkq
+	var right uint8 = 0b0100
+	if isKingSide {
+		right = 0b1000
+	}
+	if !p.WhiteToMove {
+		right >>= 2
+	}
+
+	rookSquare := m.RookStartingSquare()
+	if p.CastlingRights&right == 0 || p.Pieces[rookSquare] != p.FriendlyColor|board.Rook {
+		return false
+	}
+
+	// All squares between king and rook are empty, the king does not start in, pass through or end in check
+	step := 1
+	if !isKingSide {
+		step = -1
+	}
+	for square := from + step; square != rookSquare; square += step {
+		if occupancy&(1<<square) != 0 {
+			return false
+		}
+	}
+	for square := from; square != to+step; square += step {
+		if IsSquareAttacked(p, square, p.OpponentColor) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"time"
+)
+
+const (
+	MaxDepth = 64
+	MaxPly   = 128 // Deepest ply the search may reach, including the quiescence search
+)
+
+// SearchLimits holds everything that can restrict a search, mirroring the parameters of the UCI 'go' command
+type SearchLimits struct {
+	WhiteTime      time.Duration
+	BlackTime      time.Duration
+	WhiteIncrement time.Duration
+	BlackIncrement time.Duration
+	MovesToGo      int
+
+	MoveTime time.Duration
+	Depth    int
+	Nodes    int64
+	Mate     int
+	Infinite bool
+	Ponder   bool
+
+	// Restricts the root to these moves, searching all legal moves if empty
+	SearchMoves []board.Move
+}
+
+func (l SearchLimits) MaxDepth() int {
+	maxDepth := MaxDepth
+
+	if l.Depth > 0 {
+		maxDepth = Min(maxDepth, l.Depth)
+	}
+
+	// A mate in n moves needs 2n-1 plies to be found
+	if l.Mate > 0 {
+		maxDepth = Min(maxDepth, 2*l.Mate-1)
+	}
+
+	return maxDepth
+}
+
+func (l SearchLimits) HasClock() bool {
+	return l.WhiteTime > 0 || l.BlackTime > 0
+}
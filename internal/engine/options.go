@@ -0,0 +1,60 @@
+package engine
+
+import "time"
+
+const (
+	DefaultHashSize = 64 // Megabytes
+	MinHashSize     = 1
+	MaxHashSize     = 32768
+
+	DefaultThreads = 1
+	MaxThreads     = 256
+
+	DefaultMultiPV = 1
+	MaxMultiPV     = 256
+
+	DefaultMoveOverhead = 30 * time.Millisecond // Reserved for GUI and communication latency
+	MaxMoveOverhead     = 5 * time.Second
+)
+
+// SearchOptions are the engine settings that stay the same across searches
+type SearchOptions struct {
+	HashSize     int // Size of the transposition table in megabytes
+	Threads      int
+	MultiPV      int // Number of best lines that get searched and reported
+	MoveOverhead time.Duration
+	Features     SearchFeatures
+}
+
+// SearchFeatures switch the selective parts of the search on and off, so they can be tested against each other
+type SearchFeatures struct {
+	PrincipalVariationSearch bool
+	NullMovePruning          bool
+	LateMoveReductions       bool
+	FutilityPruning          bool
+	ReverseFutilityPruning   bool
+	CheckExtensions          bool
+	AspirationWindows        bool
+}
+
+func AllSearchFeatures() SearchFeatures {
+	return SearchFeatures{
+		PrincipalVariationSearch: true,
+		NullMovePruning:          true,
+		LateMoveReductions:       true,
+		FutilityPruning:          true,
+		ReverseFutilityPruning:   true,
+		CheckExtensions:          true,
+		AspirationWindows:        true,
+	}
+}
+
+func DefaultSearchOptions() SearchOptions {
+	return SearchOptions{
+		HashSize:     DefaultHashSize,
+		Threads:      DefaultThreads,
+		MultiPV:      DefaultMultiPV,
+		MoveOverhead: DefaultMoveOverhead,
+		Features:     AllSearchFeatures(),
+	}
+}
@@ -6,11 +6,18 @@ import (
 	"sort"
 )
 
+/*
+	Moves are ordered in bands: hash move, good captures, promotions, killer moves, the counter move, quiet moves by their
+	history score and finally captures losing material. The history scores always stay within a band of their own.
+*/
+
 const (
-	HashMove   = 10000 // Highest priority for moves from the transposition table
-	Capture    = 100   // Priority for capture moves
-	Promotion  = 90    // Priority for pawn promotions
-	KillerMove = 80    // Priority for killer moves
+	HashMove    = 1_000_000 // Highest priority for moves from the transposition table
+	Capture     = 500_000   // Priority for capture moves that do not lose material
+	Promotion   = 400_000   // Priority for pawn promotions
+	KillerMove  = 300_000   // Priority for killer moves
+	CounterMove = 200_000   // Priority for the move that last refuted the previous move
+	BadCapture  = -200_000  // Priority for capture moves that lose material according to SEE
 )
 
 type ScoredMove struct {
@@ -18,11 +25,18 @@ type ScoredMove struct {
 	Score int
 }
 
-func OrderMoves(p *board.Position, moves []board.Move, ttMove board.Move, killerMoves [2]board.Move) []board.Move {
+// OrderMoves sorts the moves by how promising they are, history may be nil when quiet moves do not need ordering
+func OrderMoves(p *board.Position, moves []board.Move, ttMove board.Move, killerMoves [2]board.Move, history *MoveHistory) []board.Move {
 	scoredMoves := make([]ScoredMove, len(moves))
 
+	counterMove := board.NullMove
+	if history != nil {
+		counterMove = history.CounterMove(p)
+	}
+
 	for i, move := range moves {
 		score := 0
+		isCapture := p.Pieces[move.To()] != 0 || move.IsEnPassant()
 
 		// Hash move (from transposition table)
 		if move == ttMove {
@@ -30,26 +44,35 @@ func OrderMoves(p *board.Position, moves []board.Move, ttMove board.Move, killer
 		}
 
 		// Captures
-		if p.Pieces[move.TargetIndex] != 0 {
-			score += Capture
-			// MVV-LVA (Most Valuable Victim - Least Valuable Attacker)
-			score += MVV_LVA(p, move)
+		if isCapture {
+			if SEE(p, move, 0) {
+				score += Capture
+				// MVV-LVA (Most Valuable Victim - Least Valuable Attacker)
+				score += MVV_LVA(p, move)
+			} else {
+				score += BadCapture
+			}
 		}
 
 		// Promotions
-		if move.PromotionPiece != 0 {
+		if move.IsPromotion() {
 			score += Promotion
 		}
 
-		// Killer moves
-		if move == killerMoves[0] {
-			score += KillerMove
-		} else if move == killerMoves[1] {
-			score += KillerMove - 1
+		// Quiet moves
+		if !isCapture && !move.IsPromotion() {
+			switch {
+			case move == killerMoves[0]:
+				score += KillerMove
+			case move == killerMoves[1]:
+				score += KillerMove - 1
+			case move == counterMove:
+				score += CounterMove
+			case history != nil:
+				score += history.Score(p, move)
+			}
 		}
 
-		// History heuristic could be added here
-
 		scoredMoves[i] = ScoredMove{Move: move, Score: score}
 	}
 
@@ -69,7 +92,7 @@ func OrderMoves(p *board.Position, moves []board.Move, ttMove board.Move, killer
 
 // MVV_LVA = (Most Valuable Victim - Least Valuable Attacker)
 func MVV_LVA(p *board.Position, m board.Move) int {
-	victim := p.Pieces[m.TargetIndex]
-	attacker := p.Pieces[m.StartIndex]
+	victim := p.Pieces[m.To()] & 0b00111
+	attacker := p.Pieces[m.From()] & 0b00111
 	return PieceValue(victim) - PieceValue(attacker)/100
 }
@@ -0,0 +1,195 @@
+package engine
+
+import "endtner.dev/nChess/internal/board"
+
+/*
+	The MovePicker hands out the moves of a node one at a time, in stages:
+
+	1. Hash move, checked for legality instead of generating moves
+	2. Good captures and promotions, by MVV-LVA, losing captures according to SEE are put aside
+	3. Killer moves
+	4. Counter move
+	5. Quiet moves, by history score
+	6. Bad captures
+
+	Moves of a stage are only generated once all stages before it are searched, and only the best remaining move is
+	selected each time. When a move causes a cutoff, the work for all later moves is skipped.
+*/
+
+type pickerStage byte
+
+const (
+	stageHashMove pickerStage = iota
+	stageGenerateCaptures
+	stageGoodCaptures
+	stageKillerMoves
+	stageCounterMove
+	stageGenerateQuiets
+	stageQuiets
+	stageBadCaptures
+	stageDone
+)
+
+type MovePicker struct {
+	p            *board.Position
+	stage        pickerStage
+	tacticalOnly bool
+
+	hashMove    board.Move
+	killerMoves [2]board.Move
+	counterMove board.Move
+	history     *MoveHistory
+
+	captures        MoveBuffer
+	captureCount    int
+	badCaptureCount int // Bad captures are moved to the front of the captures once they have been selected
+	quiets          MoveBuffer
+	quietCount      int
+	scores          [MaxMoves]int
+	index           int
+	killerIndex     int
+}
+
+// Init prepares the picker for all moves of the position, history may be nil
+func (mp *MovePicker) Init(p *board.Position, hashMove board.Move, killerMoves [2]board.Move, history *MoveHistory) {
+	*mp = MovePicker{p: p, history: history, killerMoves: killerMoves}
+
+	if IsLegal(p, hashMove) {
+		mp.hashMove = hashMove
+	}
+	if history != nil {
+		mp.counterMove = history.CounterMove(p)
+	}
+}
+
+// InitTactical prepares the picker for captures and promotions that do not lose material, as needed by the quiescence search
+func (mp *MovePicker) InitTactical(p *board.Position) {
+	*mp = MovePicker{p: p, tacticalOnly: true}
+}
+
+// Next returns the next move to search, NullMove once all moves have been returned
+func (mp *MovePicker) Next() board.Move {
+	for {
+		switch mp.stage {
+		case stageHashMove:
+			mp.stage++
+			if mp.hashMove != board.NullMove {
+				return mp.hashMove
+			}
+
+		case stageGenerateCaptures:
+			mp.captureCount = len(GenerateMoves(mp.p, CapturesOnly, &mp.captures))
+			for i, m := range mp.captures[:mp.captureCount] {
+				mp.scores[i] = tacticalScore(mp.p, m)
+			}
+			mp.index = 0
+			mp.stage++
+
+		case stageGoodCaptures:
+			for mp.index < mp.captureCount {
+				m := mp.selectBest(&mp.captures, mp.captureCount)
+				if m == mp.hashMove {
+					continue
+				}
+
+				if !m.IsPromotion() && !SEE(mp.p, m, 0) {
+					mp.captures[mp.badCaptureCount] = m
+					mp.badCaptureCount++
+					continue
+				}
+				return m
+			}
+
+			if mp.tacticalOnly {
+				mp.stage = stageDone
+			} else {
+				mp.stage++
+			}
+
+		case stageKillerMoves:
+			for mp.killerIndex < len(mp.killerMoves) {
+				m := mp.killerMoves[mp.killerIndex]
+				mp.killerIndex++
+
+				if (mp.killerIndex == 1 || m != mp.killerMoves[0]) && mp.isUnsearchedQuiet(m) {
+					return m
+				}
+			}
+			mp.stage++
+
+		case stageCounterMove:
+			mp.stage++
+			if mp.counterMove != mp.killerMoves[0] && mp.counterMove != mp.killerMoves[1] && mp.isUnsearchedQuiet(mp.counterMove) {
+				return mp.counterMove
+			}
+
+		case stageGenerateQuiets:
+			mp.quietCount = len(GenerateMoves(mp.p, QuietsOnly, &mp.quiets))
+			for i, m := range mp.quiets[:mp.quietCount] {
+				mp.scores[i] = 0
+				if mp.history != nil {
+					mp.scores[i] = mp.history.Score(mp.p, m)
+				}
+			}
+			mp.index = 0
+			mp.stage++
+
+		case stageQuiets:
+			for mp.index < mp.quietCount {
+				m := mp.selectBest(&mp.quiets, mp.quietCount)
+				if m == mp.hashMove || m == mp.killerMoves[0] || m == mp.killerMoves[1] || m == mp.counterMove {
+					continue
+				}
+				return m
+			}
+			mp.index = 0
+			mp.stage++
+
+		case stageBadCaptures:
+			if mp.index < mp.badCaptureCount {
+				mp.index++
+				return mp.captures[mp.index-1]
+			}
+			mp.stage++
+
+		default:
+			return board.NullMove
+		}
+	}
+}
+
+// selectBest swaps the best remaining move to the front of the remaining moves and returns it
+func (mp *MovePicker) selectBest(moves *MoveBuffer, count int) board.Move {
+	best := mp.index
+	for i := mp.index + 1; i < count; i++ {
+		if mp.scores[i] > mp.scores[best] {
+			best = i
+		}
+	}
+
+	moves[mp.index], moves[best] = moves[best], moves[mp.index]
+	mp.scores[mp.index], mp.scores[best] = mp.scores[best], mp.scores[mp.index]
+	mp.index++
+
+	return moves[mp.index-1]
+}
+
+// isUnsearchedQuiet checks if a killer or counter move can be played here and was not already searched as hash move
+func (mp *MovePicker) isUnsearchedQuiet(m board.Move) bool {
+	if m == board.NullMove || m == mp.hashMove || m.IsPromotion() || m.IsEnPassant() || mp.p.Pieces[m.To()] != 0 {
+		return false
+	}
+	return IsLegal(mp.p, m)
+}
+
+// tacticalScore orders captures by MVV-LVA, promotions by the piece they promote to
+func tacticalScore(p *board.Position, m board.Move) int {
+	score := MVV_LVA(p, m)
+	if m.IsEnPassant() {
+		score += PawnValue
+	}
+	if m.IsPromotion() {
+		score += PieceValue(m.PromotionType())
+	}
+	return score
+}
@@ -157,3 +157,10 @@ func sign(x int) int {
 	}
 	return 0
 }
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
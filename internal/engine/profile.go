@@ -0,0 +1,28 @@
+package engine
+
+/*
+	The move generator can measure the time spent in each of its phases. Measuring costs more than some of the phases
+	themselves, so it is only compiled in with the 'profile' build tag:
+
+		go build -tags profile .
+
+	Without the tag ProfilingEnabled is false and all measured times are zero.
+*/
+
+type ProfilePhase int
+
+const (
+	PhasePrecompute ProfilePhase = iota
+	PhaseKingGeneration
+	PhasePawnGeneration
+	PhaseSlidingGeneration
+	PhaseKnightGeneration
+
+	ProfilePhases = iota // Number of phases
+)
+
+var profilePhaseNames = [ProfilePhases]string{"Precomputation", "King Generation", "Pawn Generation", "Sliding Generation", "Knight Generation"}
+
+func (phase ProfilePhase) String() string {
+	return profilePhaseNames[phase]
+}
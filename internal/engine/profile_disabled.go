@@ -0,0 +1,18 @@
+//go:build !profile
+
+package engine
+
+import "time"
+
+const ProfilingEnabled = false
+
+func profileStart() time.Time {
+	return time.Time{}
+}
+
+func profileStop(ProfilePhase, time.Time) {}
+
+// ProfileTime returns the total time spent in a phase of the move generator by all goroutines
+func ProfileTime(ProfilePhase) time.Duration {
+	return 0
+}
@@ -0,0 +1,25 @@
+//go:build profile
+
+package engine
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const ProfilingEnabled = true
+
+var profileTimes [ProfilePhases]atomic.Int64
+
+func profileStart() time.Time {
+	return time.Now()
+}
+
+func profileStop(phase ProfilePhase, start time.Time) {
+	profileTimes[phase].Add(int64(time.Since(start)))
+}
+
+// ProfileTime returns the total time spent in a phase of the move generator by all goroutines
+func ProfileTime(phase ProfilePhase) time.Duration {
+	return time.Duration(profileTimes[phase].Load())
+}
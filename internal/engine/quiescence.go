@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"endtner.dev/nChess/internal/board"
+)
+
+const (
+	DeltaMargin = 200 // Safety margin for delta pruning in centipawns
+)
+
+/*
+	The quiescence search only follows captures and promotions until the position is quiet, which avoids the horizon effect.
+	The side to move may always "stand pat" and take the static evaluation, unless it is in check and has to answer it.
+*/
+
+func (w *worker) Quiescence(p *board.Position, ply int, alpha, beta int) int {
+	w.nodes.Add(1)
+	w.pvLength[ply] = ply
+	w.selDepth = max(w.selDepth, ply)
+	if w.shouldStop() {
+		return 0
+	}
+
+	if ply >= MaxPly {
+		return Evaluate(p)
+	}
+
+	inCheck := IsInCheck(p)
+
+	standPat := 0
+	picker := &w.movePickers[ply]
+
+	if inCheck {
+		// Every evasion has to be considered
+		picker.Init(p, board.NullMove, [2]board.Move{}, nil)
+	} else {
+		standPat = Evaluate(p)
+		if standPat >= beta {
+			return beta
+		}
+
+		// Delta pruning: not even winning a queen would bring us back to alpha
+		if standPat+QueenValue+DeltaMargin < alpha {
+			return alpha
+		}
+
+		if standPat > alpha {
+			alpha = standPat
+		}
+
+		// Captures losing material will not improve a position that is already good enough to stand pat
+		picker.InitTactical(p)
+	}
+
+	moveCount := 0
+	for m := picker.Next(); m != board.NullMove; m = picker.Next() {
+		moveCount++
+
+		// Delta pruning per move: the captured piece plus a margin does not reach alpha
+		if !inCheck && !m.IsPromotion() {
+			capturedValue := PieceValue(p.Pieces[m.To()] & 0b00111)
+			if m.IsEnPassant() {
+				capturedValue = PawnValue
+			}
+
+			if standPat+capturedValue+DeltaMargin <= alpha {
+				continue
+			}
+		}
+
+		p.MakeMove(m)
+		score := -w.Quiescence(p, ply+1, -beta, -alpha)
+		p.UnmakeMove()
+
+		if w.aborted {
+			return 0
+		}
+
+		if score >= beta {
+			return beta
+		}
+		if score > alpha {
+			alpha = score
+			w.updatePV(ply, m)
+		}
+	}
+
+	// Without any legal evasion we are checkmated
+	if inCheck && moveCount == 0 {
+		return max(alpha, MatedIn(ply))
+	}
+
+	return alpha
+}
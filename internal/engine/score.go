@@ -0,0 +1,58 @@
+package engine
+
+/*
+	Scores are integers in centipawns from the view of the side to move. Checkmates live in their own range above all
+	evaluations: being mated at ply n scores -MateScore+n, so quicker mates score better for the winning side.
+
+	Mate scores depend on the distance to the root, which differs between positions sharing a transposition table entry.
+	The table therefore stores them relative to the position itself and they are converted back on every read.
+*/
+
+const (
+	Infinity       = 32000
+	MateScore      = 31000
+	MateBoundScore = MateScore - MaxPly // Every score beyond this bound is a mate score
+	DrawScore      = 0
+)
+
+// MatedIn is the score of the side to move getting mated at ply
+func MatedIn(ply int) int {
+	return -MateScore + ply
+}
+
+// MateIn is the score of the side to move mating at ply
+func MateIn(ply int) int {
+	return MateScore - ply
+}
+
+func IsMateScore(score int) bool {
+	return score >= MateBoundScore || score <= -MateBoundScore
+}
+
+// MateInMoves converts a mate score into full moves until mate as reported by UCI, negative if the side to move gets mated
+func MateInMoves(score int) int {
+	if score > 0 {
+		return (MateScore - score + 1) / 2
+	}
+	return -(MateScore + score) / 2
+}
+
+// scoreToTT makes mate scores relative to the position stored at ply
+func scoreToTT(score, ply int) int {
+	if score >= MateBoundScore {
+		return score + ply
+	} else if score <= -MateBoundScore {
+		return score - ply
+	}
+	return score
+}
+
+// scoreFromTT makes mate scores read at ply relative to the root again
+func scoreFromTT(score, ply int) int {
+	if score >= MateBoundScore {
+		return score - ply
+	} else if score <= -MateBoundScore {
+		return score + ply
+	}
+	return score
+}
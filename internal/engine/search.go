@@ -2,100 +2,205 @@ package engine
 
 import (
 	"endtner.dev/nChess/internal/board"
-	"math"
-	"time"
+	"slices"
+	"sync"
+	"sync/atomic"
 )
 
-var (
-	historyTable [64][64]int
-	counterMoves [64][64]board.Move
-)
+/*
+	A Searcher lives as long as the game it is used for, so the transposition table carries knowledge from one move to the next.
+	Stop and PonderHit may be called from other goroutines while a search is running.
+
+	With more than one thread the search uses Lazy SMP: helper workers search the same root position in parallel and only
+	share their results through the transposition table. The main worker reports progress, manages the time and decides
+	the move, the helpers are stopped as soon as it is done.
+*/
+
+type Searcher struct {
+	Options SearchOptions
+
+	// Optional callbacks for reporting progress, they are called from the searching goroutine
+	OnIteration   func(SearchInfo)
+	OnCurrentMove func(CurrentMoveInfo)
+
+	tt      *TranspositionTable
+	workers []*worker // The first worker is the main worker
+	rootPV  []board.Move
+
+	limits      SearchLimits
+	timeManager *TimeManager // Only touched by the main worker
+	finished    atomic.Bool  // Set once the main worker is done, so the helpers stop as well
 
-func IterativeDeepeningSearch(p *board.Position, maxDepth int, timeLimit time.Duration) board.Move {
-	tt := NewTranspositionTable()
-	killerMoves := make([][2]board.Move, maxDepth+1)
+	signals *searchSignals
+}
+
+// searchSignals are armed freshly for every search, so a late stop can never abort the following search
+type searchSignals struct {
+	stopRequested atomic.Bool
+	stopOnce      sync.Once
+	stopChan      chan struct{}
+	ponderOnce    sync.Once
+	ponderChan    chan struct{}
+}
 
-	pv := make([][]board.Move, maxDepth+1)
-	for i := range pv {
-		pv[i] = make([]board.Move, maxDepth+1)
+func newSearchSignals() *searchSignals {
+	return &searchSignals{
+		stopChan:   make(chan struct{}),
+		ponderChan: make(chan struct{}),
 	}
+}
 
-	startTime := time.Now()
-	var bestMove board.Move
+func NewSearcher() *Searcher {
+	options := DefaultSearchOptions()
 
-	for depth := 1; depth <= maxDepth; depth++ {
-		// Time management: check if we have enough time for the next iteration
-		elapsedTime := time.Since(startTime)
-		if elapsedTime > timeLimit {
-			break
-		}
+	return &Searcher{
+		Options: options,
+		tt:      NewTranspositionTable(options.HashSize),
+		workers: []*worker{newWorker(0)},
+		signals: newSearchSignals(),
+	}
+}
+
+// IterativeDeepeningSearch runs a blocking search without any way to interrupt it besides the limits
+func IterativeDeepeningSearch(p *board.Position, limits SearchLimits) board.Move {
+	return NewSearcher().Search(p, limits)
+}
 
-		alpha := math.Inf(-1)
-		beta := math.Inf(1)
+// Search blocks until the search is finished
+func (s *Searcher) Search(p *board.Position, limits SearchLimits) board.Move {
+	s.signals = newSearchSignals()
+	return s.search(p, limits)
+}
 
-		NegaMax(p, depth, alpha, beta, tt, killerMoves, pv)
+// Start runs the search in the background, the best move is sent on the returned channel. Stop and PonderHit apply to this search from the moment Start returns
+func (s *Searcher) Start(p *board.Position, limits SearchLimits) <-chan board.Move {
+	s.signals = newSearchSignals()
 
-		// Retrieve the best move from the transposition table
-		if entry, found := tt.Probe(p.Zobrist); found && entry.Depth == depth {
-			bestMove = entry.Move
-		}
+	result := make(chan board.Move, 1)
+	go func() {
+		result <- s.search(p, limits)
+	}()
 
-		// Time management: check if we have enough time for the next iteration
-		elapsedTime = time.Since(startTime)
-		if elapsedTime > timeLimit/2 {
-			break
-		}
+	return result
+}
+
+// NewGame forgets everything learned in previous games
+func (s *Searcher) NewGame() {
+	s.ClearHash()
+	for _, w := range s.workers {
+		w.clearHistory()
 	}
+}
 
-	return bestMove
+func (s *Searcher) ClearHash() {
+	s.tt.Clear()
 }
 
-func NegaMax(p *board.Position, depth int, alpha, beta float64, tt *TranspositionTable, killerMoves [][2]board.Move, pv [][]board.Move) float64 {
-	// Original alpha value, used for updating the transposition table
-	alpha0 := alpha
+func (s *Searcher) search(p *board.Position, limits SearchLimits) board.Move {
+	maxDepth := limits.MaxDepth()
 
-	// Transposition table lookup
-	ttMove, shouldReturn, ttScore := tt.Query(p.Zobrist, depth, alpha, beta)
-	if shouldReturn {
-		return ttScore
+	// The hash size and the number of threads may have been changed since the last search
+	if s.tt.SizeMB() != s.Options.HashSize {
+		s.tt = NewTranspositionTable(s.Options.HashSize)
+	}
+	s.resizeWorkers(max(s.Options.Threads, 1))
+	s.tt.NewSearch()
+
+	s.rootPV = nil
+	s.limits = limits
+	s.timeManager = NewTimeManager(limits, p.WhiteToMove, s.Options.MoveOverhead)
+	s.finished.Store(false)
+
+	rootMoves := LegalMoves(p)
+	if len(limits.SearchMoves) > 0 {
+		rootMoves = slices.DeleteFunc(rootMoves, func(m board.Move) bool {
+			return !slices.Contains(limits.SearchMoves, m)
+		})
+	}
+	if len(rootMoves) == 0 {
+		return board.NullMove
 	}
 
-	// Retuning if depth is reached OR the position is terminal
-	if depth == 0 || p.IsTerminal {
-		return Evaluate(p)
+	for _, w := range s.workers {
+		w.reset(s)
 	}
 
-	// Ordering the moves
-	orderedMoves := OrderMoves(p, LegalMoves(p), ttMove, killerMoves[depth])
+	var helpers sync.WaitGroup
+	for _, w := range s.workers[1:] {
+		helpers.Add(1)
+		go func() {
+			defer helpers.Done()
+			w.iterativeDeepening(p.Copy(), rootMoves, maxDepth)
+		}()
+	}
 
-	// Alpha-Beta-Pruned search
-	var bestMove board.Move
-	currentEval := math.Inf(-1)
+	bestMove := s.workers[0].iterativeDeepening(p.Copy(), rootMoves, maxDepth)
 
-	for _, m := range orderedMoves {
-		np := p.MakeMove(m)
+	s.finished.Store(true)
+	helpers.Wait()
 
-		// min(a, b) = -max(-b, -a)
-		score := -NegaMax(np, depth-1, -beta, -alpha, tt, killerMoves, pv)
+	return bestMove
+}
 
-		if score > currentEval {
-			currentEval = score
-			bestMove = m
-		}
-		alpha = math.Max(alpha, currentEval)
-
-		// Move is too good (killer move), opponent will play another move
-		if alpha >= beta {
-			if p.Pieces[m.TargetIndex] == 0 {
-				killerMoves[depth][1] = killerMoves[depth][0]
-				killerMoves[depth][0] = m
-			}
-			break
-		}
+func (s *Searcher) resizeWorkers(threads int) {
+	for len(s.workers) < threads {
+		s.workers = append(s.workers, newWorker(len(s.workers)))
+	}
+	s.workers = s.workers[:threads]
+}
+
+// nodesSearched sums up the nodes of all workers, it may be called while they are searching
+func (s *Searcher) nodesSearched() int64 {
+	var nodes int64
+	for _, w := range s.workers {
+		nodes += w.nodes.Load()
+	}
+	return nodes
+}
+
+// PrincipalVariation returns the line the engine expects after the last completed iteration, starting with the best move
+func (s *Searcher) PrincipalVariation() []board.Move {
+	return s.rootPV
+}
+
+// Stop aborts the running search, Search then returns the best move of the last completed iteration
+func (s *Searcher) Stop() {
+	signals := s.signals
+	signals.stopOnce.Do(func() {
+		signals.stopRequested.Store(true)
+		close(signals.stopChan)
+	})
+}
+
+// PonderHit tells a pondering search that the expected move was played, so the time limits start to apply
+func (s *Searcher) PonderHit() {
+	signals := s.signals
+	signals.ponderOnce.Do(func() {
+		close(signals.ponderChan)
+	})
+}
+
+// checkPonderHit forwards a ponderhit to the time manager, which is only ever touched by the searching goroutine
+func (s *Searcher) checkPonderHit() {
+	if !s.timeManager.IsPondering() {
+		return
 	}
 
-	// Storing in TT
-	tt.Store(p.Zobrist, depth, currentEval, alpha0, beta, bestMove)
+	select {
+	case <-s.signals.ponderChan:
+		s.timeManager.PonderHit()
+	default:
+	}
+}
 
-	return alpha
+// WaitForRelease blocks until a finished search may report its move: infinite searches wait for Stop, pondering searches for Stop or PonderHit
+func (s *Searcher) WaitForRelease() {
+	if s.limits.Infinite {
+		<-s.signals.stopChan
+	} else if s.limits.Ponder {
+		select {
+		case <-s.signals.stopChan:
+		case <-s.signals.ponderChan:
+		}
+	}
 }
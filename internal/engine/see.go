@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"math/bits"
+)
+
+/*
+	Static Exchange Evaluation: plays out all captures on the target square of a move, always recapturing with the least
+	valuable attacker, and checks whether the side making the move comes out with at least the threshold in centipawns.
+	Sliders hidden behind other attackers (x-rays) join the exchange once the piece in front of them has captured.
+	Pins are not taken into account.
+*/
+
+var seeAttackerOrder = []uint8{board.Pawn, board.Knight, board.Bishop, board.Rook, board.Queen, board.King}
+
+func SEE(p *board.Position, m board.Move, threshold int) bool {
+	// Castling and promotions are not resolved, they count as an even exchange
+	if m.IsCastle() || m.IsPromotion() {
+		return threshold <= 0
+	}
+
+	from, to := m.From(), m.To()
+	occupancy := ColorPieces(p, board.White) | ColorPieces(p, board.Black)
+
+	capturedValue := PieceValue(p.Pieces[to] & 0b00111)
+	if m.IsEnPassant() {
+		capturedValue = PawnValue
+		occupancy ^= 1 << m.EnPassantCaptureSquare()
+	}
+
+	// Even winning the captured piece for free does not reach the threshold
+	swap := capturedValue - threshold
+	if swap < 0 {
+		return false
+	}
+
+	// Even losing the moved piece reaches the threshold
+	swap = PieceValue(p.Pieces[from]&0b00111) - swap
+	if swap <= 0 {
+		return true
+	}
+
+	occupancy ^= 1<<from | 1<<to
+
+	diagonalSliders := p.Bitboards[board.White|board.Bishop] | p.Bitboards[board.Black|board.Bishop] | p.Bitboards[board.White|board.Queen] | p.Bitboards[board.Black|board.Queen]
+	orthogonalSliders := p.Bitboards[board.White|board.Rook] | p.Bitboards[board.Black|board.Rook] | p.Bitboards[board.White|board.Queen] | p.Bitboards[board.Black|board.Queen]
+
+	attackers := AttackersTo(p, to, occupancy)
+	color := p.FriendlyColor
+	result := 1
+
+	for {
+		color ^= board.Black
+		attackers &= occupancy
+
+		colorAttackers := attackers & ColorPieces(p, color)
+		if colorAttackers == 0 {
+			break
+		}
+
+		result ^= 1
+
+		// Finding the least valuable attacker
+		pieceType := board.Pawn
+		for _, pt := range seeAttackerOrder {
+			if colorAttackers&p.Bitboards[color|pt] != 0 {
+				pieceType = pt
+				break
+			}
+		}
+
+		// The king may only capture if the opponent has no attackers left
+		if pieceType == board.King {
+			if attackers&^ColorPieces(p, color) != 0 {
+				return result^1 == 1
+			}
+			return result == 1
+		}
+
+		swap = PieceValue(pieceType) - swap
+		if swap < result {
+			break
+		}
+
+		attacker := colorAttackers & p.Bitboards[color|pieceType]
+		occupancy ^= 1 << bits.TrailingZeros64(attacker)
+
+		// Adding x-ray attackers behind the piece that just captured
+		if pieceType == board.Pawn || pieceType == board.Bishop || pieceType == board.Queen {
+			attackers |= PGetBishopMoves(to, occupancy) & diagonalSliders
+		}
+		if pieceType == board.Rook || pieceType == board.Queen {
+			attackers |= PGetRookMoves(to, occupancy) & orthogonalSliders
+		}
+	}
+
+	return result == 1
+}
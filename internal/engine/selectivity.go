@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"math"
+)
+
+/*
+	Parameters of the selective search. Each technique can be switched off through SearchFeatures.
+
+	- Null move pruning: if passing the turn still fails high on a reduced search, a real move will most likely do too
+	- Late move reductions: moves ordered late rarely turn out best, so quiet ones are searched less deep first
+	- Futility pruning: close to the horizon, quiet moves can not lift a hopeless static evaluation above alpha
+	- Reverse futility pruning: close to the horizon, a static evaluation far above beta will most likely hold
+*/
+
+const (
+	NullMoveMinDepth  = 3
+	NullMoveReduction = 3 // Base reduction, every 6 plies of depth reduce one ply more
+
+	LMRMinDepth     = 3
+	LMRMinMoveIndex = 3 // The first moves of every node are never reduced
+
+	FutilityMaxDepth = 3
+	FutilityMargin   = 100 // Centipawns per ply of remaining depth
+
+	ReverseFutilityMaxDepth = 6
+	ReverseFutilityMargin   = 80 // Centipawns per ply of remaining depth
+
+	AspirationMinDepth = 4
+	AspirationWindow   = 25 // Initial half width in centipawns, doubled on every fail
+)
+
+// lateMoveReductions grow logarithmically with both the remaining depth and the index of the move
+var lateMoveReductions = func() [MaxDepth + 1][MaxMoves]int {
+	var reductions [MaxDepth + 1][MaxMoves]int
+	for depth := 1; depth <= MaxDepth; depth++ {
+		for moveIndex := 1; moveIndex < MaxMoves; moveIndex++ {
+			reductions[depth][moveIndex] = int(0.75 + math.Log(float64(depth))*math.Log(float64(moveIndex))/2.25)
+		}
+	}
+	return reductions
+}()
+
+func lateMoveReduction(depth, moveIndex int, pvNode bool, historyScore int) int {
+	reduction := lateMoveReductions[min(depth, MaxDepth)][min(moveIndex, MaxMoves-1)]
+
+	if pvNode {
+		reduction--
+	}
+
+	// Moves that caused cutoffs before are reduced less
+	if historyScore > 0 {
+		reduction--
+	}
+
+	// Always leave at least one ply to search
+	return max(min(reduction, depth-2), 0)
+}
+
+// hasNonPawnMaterial guards null move pruning against zugzwang, which mostly happens in pawn endgames
+func hasNonPawnMaterial(p *board.Position) bool {
+	color := p.FriendlyColor
+	return p.Bitboards[color|board.Knight]|p.Bitboards[color|board.Bishop]|p.Bitboards[color|board.Rook]|p.Bitboards[color|board.Queen] != 0
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"time"
+)
+
+const (
+	DefaultMovesToGo = 30 // Assumed number of moves left when the GUI does not send movestogo
+	HardLimitFactor  = 4  // Hard budget relative to the soft budget
+)
+
+/*
+	The time manager hands out two budgets per move:
+	- The soft limit is checked between iterations, no new iteration is started once it is exceeded
+	- The hard limit is checked during the search, the running iteration is aborted once it is exceeded
+
+	While pondering no budget applies. On a ponderhit the budgets start counting from that moment on.
+*/
+
+type TimeManager struct {
+	startTime time.Time
+	softLimit time.Duration
+	hardLimit time.Duration
+	isLimited bool
+
+	pondering    bool
+	budgetOffset time.Duration // Time between the start of the search and the ponderhit
+}
+
+func NewTimeManager(limits SearchLimits, whiteToMove bool, moveOverhead time.Duration) *TimeManager {
+	tm := &TimeManager{startTime: time.Now(), pondering: limits.Ponder}
+
+	if limits.Infinite {
+		return tm
+	}
+
+	// Fixed time per move
+	if limits.MoveTime > 0 {
+		tm.isLimited = true
+		tm.softLimit = max(limits.MoveTime-moveOverhead, 0)
+		tm.hardLimit = tm.softLimit
+		return tm
+	}
+
+	if !limits.HasClock() {
+		return tm
+	}
+
+	remaining, increment := limits.WhiteTime, limits.WhiteIncrement
+	if !whiteToMove {
+		remaining, increment = limits.BlackTime, limits.BlackIncrement
+	}
+
+	movesToGo := limits.MovesToGo
+	if movesToGo <= 0 {
+		movesToGo = DefaultMovesToGo
+	}
+
+	// Never plan with the time we need to send the move back
+	available := max(remaining-moveOverhead, 0)
+
+	tm.isLimited = true
+	tm.softLimit = available/time.Duration(movesToGo) + increment*3/4
+	tm.hardLimit = min(tm.softLimit*HardLimitFactor, available*4/5)
+	tm.softLimit = min(tm.softLimit, tm.hardLimit)
+
+	return tm
+}
+
+func (tm *TimeManager) Elapsed() time.Duration {
+	return time.Since(tm.startTime)
+}
+
+func (tm *TimeManager) IsPondering() bool {
+	return tm.pondering
+}
+
+func (tm *TimeManager) PonderHit() {
+	tm.budgetOffset = tm.Elapsed()
+	tm.pondering = false
+}
+
+func (tm *TimeManager) budgetElapsed() time.Duration {
+	return tm.Elapsed() - tm.budgetOffset
+}
+
+// Budgets returns the soft and hard limit of the move, limited is false if the search may run until it is stopped
+func (tm *TimeManager) Budgets() (soft, hard time.Duration, limited bool) {
+	return tm.softLimit, tm.hardLimit, tm.isLimited
+}
+
+func (tm *TimeManager) SoftLimitReached() bool {
+	return tm.isLimited && !tm.IsPondering() && tm.budgetElapsed() >= tm.softLimit
+}
+
+func (tm *TimeManager) HardLimitReached() bool {
+	return tm.isLimited && !tm.IsPondering() && tm.budgetElapsed() >= tm.hardLimit
+}
@@ -2,11 +2,8 @@ package engine
 
 import (
 	"endtner.dev/nChess/internal/board"
-	"math"
-)
-
-const (
-	TableSize = 1024 * 1024 * 1024 / 32 // Number of entries for 1GB table
+	"sync/atomic"
+	"unsafe"
 )
 
 type EntryType byte
@@ -18,24 +15,71 @@ const (
 )
 
 type Entry struct {
-	Key   uint64     // Zobrist hash of the position
-	Depth int        // Depth of the search when this entry was created
-	Score float64    // Evaluation score
-	Type  EntryType  // Type of the score (exact, lower bound, upper bound)
-	Move  board.Move // Best move found for this position
+	Key        uint64     // Zobrist hash of the position
+	Depth      int        // Depth of the search when this entry was created
+	Score      int        // Evaluation score, mate scores are relative to the position
+	Type       EntryType  // Type of the score (exact, lower bound, upper bound)
+	Generation uint32     // Search the entry was written in, used to tell stale entries apart
+	Move       board.Move // Best move found for this position
 }
 
+/*
+	The table is kept across searches. Every search increases the generation, entries of older generations are always
+	replaced, while entries of the current search are only replaced by entries of at least the same depth.
+
+	All search threads share the table without any locking. A slot stores the key xor-ed with the packed data, so a slot
+	that was torn by two threads writing to it at the same time no longer matches its key and simply counts as a miss.
+*/
+
 type TranspositionTable struct {
-	table []Entry
+	table      []slot
+	size       uint64
+	sizeMB     int
+	generation uint32 // Wide enough to never wrap around, an old entry would look like one of the running search again
 }
 
-func NewTranspositionTable() *TranspositionTable {
+type slot struct {
+	check atomic.Uint64 // Key ^ score ^ data
+	score atomic.Uint64 // Bits of the score
+	data  atomic.Uint64 // Move, depth, type and generation
+}
+
+// The move takes the lowest 16 bits of the data and the generation the highest 32, the used bit tells a stored null move
+// apart from an empty slot
+const (
+	dataDepthShift      = 16
+	dataTypeShift       = 24
+	dataUsedBit         = 1 << 26
+	dataGenerationShift = 32
+)
+
+// NewTranspositionTable allocates a table using roughly sizeMB megabytes
+func NewTranspositionTable(sizeMB int) *TranspositionTable {
+	size := max(uint64(sizeMB)*1024*1024/uint64(unsafe.Sizeof(slot{})), 1)
+
 	return &TranspositionTable{
-		table: make([]Entry, TableSize),
+		table:  make([]slot, size),
+		size:   size,
+		sizeMB: sizeMB,
 	}
 }
 
-func (tt *TranspositionTable) Store(key uint64, depth int, score, alpha0, beta float64, move board.Move) {
+func (tt *TranspositionTable) SizeMB() int {
+	return tt.sizeMB
+}
+
+// NewSearch ages all existing entries, it must not be called while a search is running
+func (tt *TranspositionTable) NewSearch() {
+	tt.generation++
+}
+
+func (tt *TranspositionTable) Clear() {
+	clear(tt.table)
+	tt.generation = 0
+}
+
+// Store saves the result of a search at ply, alpha0 and beta are the bounds the search started with
+func (tt *TranspositionTable) Store(key uint64, depth, ply, score, alpha0, beta int, move board.Move) {
 	var entryType EntryType
 	if score <= alpha0 {
 		entryType = UpperBound
@@ -45,39 +89,92 @@ func (tt *TranspositionTable) Store(key uint64, depth int, score, alpha0, beta f
 		entryType = ExactScore
 	}
 
-	index := key % TableSize
-	tt.table[index] = Entry{Key: key, Depth: depth, Score: score, Type: entryType, Move: move}
+	s := &tt.table[key%tt.size]
+	existing, valid := s.load()
+
+	// Keep the deeper entry of the running search, unless it is the same position
+	if valid && existing.Key != key && existing.Generation == tt.generation && existing.Depth > depth {
+		return
+	}
+
+	// Do not lose the best move of a position when a search did not find one
+	if valid && existing.Key == key && move == board.NullMove {
+		move = existing.Move
+	}
+
+	s.store(Entry{Key: key, Depth: depth, Score: scoreToTT(score, ply), Type: entryType, Generation: tt.generation, Move: move})
 }
 
-func (tt *TranspositionTable) Query(key uint64, depth int, alpha, beta float64) (board.Move, bool, float64) {
-	ttMove := board.Move{}
-	entry := tt.table[key%TableSize]
-	found := entry.Key != 0
+func (tt *TranspositionTable) Query(key uint64, depth, ply, alpha, beta int) (board.Move, bool, int) {
+	entry, found := tt.Probe(key)
+	if !found {
+		return board.NullMove, false, 0
+	}
 
-	if found && entry.Depth >= depth {
-		ttMove = entry.Move
+	score := scoreFromTT(entry.Score, ply)
 
+	// The best move is worth trying first, even if the entry is too shallow to trust its score
+	ttMove := entry.Move
+
+	if entry.Depth >= depth {
 		if entry.Type == ExactScore {
-			return ttMove, true, entry.Score
+			return ttMove, true, score
 		} else if entry.Type == LowerBound {
-			alpha = math.Max(alpha, entry.Score)
+			alpha = max(alpha, score)
 		} else if entry.Type == UpperBound {
-			beta = math.Min(beta, entry.Score)
+			beta = min(beta, score)
 		}
 
 		// Move already got evaluated better than the current search
 		if alpha >= beta {
-			return ttMove, true, entry.Score
+			return ttMove, true, score
 		}
 	}
 	return ttMove, false, 0
 }
 
 func (tt *TranspositionTable) Probe(key uint64) (Entry, bool) {
-	index := key % TableSize
-	entry := tt.table[index]
-	if entry.Key == key {
+	entry, valid := tt.table[key%tt.size].load()
+	if valid && entry.Key == key {
 		return entry, true
 	}
 	return Entry{}, false
 }
+
+// HashFull estimates the share of the table written by the current search in permill by sampling the first thousand entries
+func (tt *TranspositionTable) HashFull() int {
+	used := 0
+	for i := range min(1000, tt.size) {
+		entry, valid := tt.table[i].load()
+		if valid && entry.Generation == tt.generation {
+			used++
+		}
+	}
+	return used * 1000 / int(min(1000, tt.size))
+}
+
+// load decodes the slot, an empty slot is reported as invalid
+func (s *slot) load() (Entry, bool) {
+	check, score, data := s.check.Load(), s.score.Load(), s.data.Load()
+	if data == 0 {
+		return Entry{}, false
+	}
+
+	return Entry{
+		Key:        check ^ score ^ data,
+		Depth:      int(uint8(data >> dataDepthShift)),
+		Score:      int(int64(score)),
+		Type:       EntryType(data >> dataTypeShift & 0b11),
+		Generation: uint32(data >> dataGenerationShift),
+		Move:       board.Move(data),
+	}, true
+}
+
+func (s *slot) store(e Entry) {
+	score := uint64(int64(e.Score))
+	data := uint64(e.Move) | uint64(uint8(e.Depth))<<dataDepthShift | uint64(e.Type)<<dataTypeShift | uint64(e.Generation)<<dataGenerationShift | dataUsedBit
+
+	s.check.Store(e.Key ^ score ^ data)
+	s.score.Store(score)
+	s.data.Store(data)
+}
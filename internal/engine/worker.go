@@ -0,0 +1,463 @@
+package engine
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"slices"
+	"sync/atomic"
+)
+
+/*
+	A worker holds everything a single search thread writes to, so workers never have to synchronize with each other.
+	The searcher only reads the node counters of the workers while they are running.
+*/
+
+type worker struct {
+	id       int
+	searcher *Searcher
+
+	killerMoves [MaxPly + 1][2]board.Move
+	history     *MoveHistory // Large, so it is allocated separately
+
+	pv          [MaxPly + 1][MaxPly + 1]board.Move
+	pvLength    [MaxPly + 1]int
+	movePickers [MaxPly + 1]MovePicker // Moves of every ply, so the search does not allocate them
+	evasions    MoveBuffer             // Tells a checkmate apart from a draw by the fifty-move rule
+
+	nodes          atomic.Int64
+	selDepth       int
+	completedDepth int
+	aborted        bool
+}
+
+func newWorker(id int) *worker {
+	return &worker{id: id, history: new(MoveHistory)}
+}
+
+func (w *worker) isMain() bool {
+	return w.id == 0
+}
+
+// reset prepares the worker for a new search, the history tables are kept as they are still useful for the next move
+func (w *worker) reset(s *Searcher) {
+	w.searcher = s
+	w.killerMoves = [MaxPly + 1][2]board.Move{}
+	w.nodes.Store(0)
+	w.completedDepth = 0
+	w.aborted = false
+}
+
+func (w *worker) clearHistory() {
+	w.history.Clear()
+}
+
+// iterativeDeepening makes and unmakes moves on p, so every worker needs its own copy of the root position
+func (w *worker) iterativeDeepening(p *board.Position, rootMoves []board.Move, maxDepth int) board.Move {
+	s := w.searcher
+
+	bestMove := rootMoves[0]
+
+	// Helpers only search the best line and report nothing
+	multiPV := 1
+	if w.isMain() {
+		multiPV = Min(max(s.Options.MultiPV, 1), len(rootMoves))
+	}
+
+	// Every other helper starts one iteration deeper, so the threads do not all search the same depth at the same time
+	startDepth := 1 + w.id%2
+
+	var previousLineMoves []board.Move
+	previousScores := make([]int, multiPV)
+
+	for depth := min(startDepth, maxDepth); depth <= maxDepth; depth++ {
+		w.selDepth = 0
+
+		// Every further line is searched without the best moves of the lines before it
+		var lineMoves []board.Move
+		for pvIndex := range multiPV {
+			candidates := slices.DeleteFunc(slices.Clone(rootMoves), func(m board.Move) bool {
+				return slices.Contains(lineMoves, m)
+			})
+
+			// The best move of the last iteration is searched first, as it will most likely stay the best move
+			firstMove := board.NullMove
+			if pvIndex < len(previousLineMoves) {
+				firstMove = previousLineMoves[pvIndex]
+			}
+
+			move, score := w.aspirationSearch(p, candidates, depth, previousScores[pvIndex], firstMove)
+
+			// Results of an aborted iteration are incomplete, so we keep the move of the last full iteration
+			if w.aborted {
+				break
+			}
+
+			lineMoves = append(lineMoves, move)
+			previousScores[pvIndex] = score
+			if pvIndex == 0 {
+				bestMove = move
+			}
+			if !w.isMain() {
+				continue
+			}
+
+			linePV := slices.Clone(w.pv[0][:w.pvLength[0]])
+			if pvIndex == 0 {
+				s.rootPV = linePV
+			}
+
+			if s.OnIteration != nil {
+				s.OnIteration(SearchInfo{
+					MultiPV:  pvIndex + 1,
+					Depth:    depth,
+					SelDepth: w.selDepth,
+					Score:    score,
+					Nodes:    s.nodesSearched(),
+					Time:     s.timeManager.Elapsed(),
+					HashFull: s.tt.HashFull(),
+					PV:       linePV,
+				})
+			}
+		}
+
+		if w.aborted {
+			break
+		}
+		w.completedDepth = depth
+		previousLineMoves = lineMoves
+
+		// Time management: do not start another iteration we will most likely not finish
+		if w.isMain() {
+			s.checkPonderHit()
+			if s.signals.stopRequested.Load() || s.timeManager.SoftLimitReached() {
+				break
+			}
+		}
+	}
+
+	return bestMove
+}
+
+/*
+	Aspiration windows: the score of an iteration is usually close to the score of the one before, so the root is searched
+	with a narrow window around it, which cuts off much more. If the score falls outside the window, the search is repeated
+	with a window widened on the failing side, until the score lies within.
+*/
+
+func (w *worker) aspirationSearch(p *board.Position, rootMoves []board.Move, depth, previousScore int, firstMove board.Move) (board.Move, int) {
+	// Shallow iterations are too unstable and mate scores too far apart for a narrow window
+	if !w.searcher.Options.Features.AspirationWindows || depth < AspirationMinDepth || IsMateScore(previousScore) {
+		return w.searchRoot(p, rootMoves, depth, -Infinity, Infinity, firstMove)
+	}
+
+	delta := AspirationWindow
+	alpha := max(previousScore-delta, -Infinity)
+	beta := min(previousScore+delta, Infinity)
+
+	for {
+		move, score := w.searchRoot(p, rootMoves, depth, alpha, beta, firstMove)
+		if w.aborted {
+			return move, score
+		}
+
+		switch {
+		case score <= alpha:
+			alpha = max(score-delta, -Infinity)
+		case score >= beta:
+			beta = min(score+delta, Infinity)
+			// Search the move that failed high first, it is the new best move
+			firstMove = move
+		default:
+			return move, score
+		}
+
+		delta *= 2
+	}
+}
+
+func (w *worker) searchRoot(p *board.Position, rootMoves []board.Move, depth, alpha, beta int, firstMove board.Move) (board.Move, int) {
+	s := w.searcher
+
+	hashMove := firstMove
+	if hashMove == board.NullMove || !slices.Contains(rootMoves, hashMove) {
+		hashMove, _, _ = s.tt.Query(p.Zobrist, depth, 0, alpha, beta)
+	}
+	orderedMoves := OrderMoves(p, rootMoves, hashMove, w.killerMoves[0], w.history)
+
+	bestMove := orderedMoves[0]
+	w.pvLength[0] = 0
+
+	for i, m := range orderedMoves {
+		if w.isMain() && s.OnCurrentMove != nil && s.timeManager.Elapsed() > CurrentMoveReportDelay {
+			s.OnCurrentMove(CurrentMoveInfo{Depth: depth, Move: m, Number: i + 1})
+		}
+
+		p.MakeMove(m)
+
+		var score int
+		if i == 0 || !s.Options.Features.PrincipalVariationSearch {
+			score = -w.NegaMax(p, depth-1, 1, -beta, -alpha, true)
+		} else {
+			// Only moves that beat the best move so far are searched again with the full window
+			score = -w.NegaMax(p, depth-1, 1, -alpha-1, -alpha, true)
+			if score > alpha {
+				score = -w.NegaMax(p, depth-1, 1, -beta, -alpha, true)
+			}
+		}
+
+		p.UnmakeMove()
+
+		if w.aborted {
+			return bestMove, alpha
+		}
+
+		if score > alpha {
+			alpha = score
+			bestMove = m
+			w.updatePV(0, m)
+		}
+
+		// Fail high, the aspiration window has to be widened
+		if alpha >= beta {
+			break
+		}
+	}
+
+	return bestMove, alpha
+}
+
+/*
+	NegaMax is a fail-hard principal variation search. The first move of a node is searched with the full window, all
+	later moves only have to prove that they are not better than it, which a zero window search does much quicker. Nodes
+	with a zero window (beta = alpha + 1) are no PV nodes, most of the pruning only happens there.
+*/
+
+func (w *worker) NegaMax(p *board.Position, depth, ply int, alpha, beta int, allowNull bool) int {
+	s := w.searcher
+	features := s.Options.Features
+
+	w.nodes.Add(1)
+	w.pvLength[ply] = ply
+	w.selDepth = max(w.selDepth, ply)
+	if w.shouldStop() {
+		return 0
+	}
+
+	// A repeated position is a draw, as the side that repeated it can simply keep repeating it, and so is a position
+	// without mating material. The fifty-move rule only draws if the move that reached it did not checkmate
+	if p.IsRepetition() || p.IsInsufficientMaterial() {
+		return DrawScore
+	}
+	if p.IsFiftyMoveRule() && (!IsInCheck(p) || len(GenerateMoves(p, AllMoves, &w.evasions)) > 0) {
+		return DrawScore
+	}
+
+	if ply >= MaxPly {
+		return Evaluate(p)
+	}
+
+	// Mate distance pruning: even mating right now would not beat a quicker mate found elsewhere
+	alpha = max(alpha, MatedIn(ply))
+	beta = min(beta, MateIn(ply+1))
+	if alpha >= beta {
+		return alpha
+	}
+
+	inCheck := IsInCheck(p)
+
+	// Check extension: checks are resolved first, so forcing lines are not cut off at the horizon
+	if inCheck && features.CheckExtensions {
+		depth++
+	}
+
+	// Original alpha value, used for updating the transposition table
+	alpha0 := alpha
+
+	pvNode := beta-alpha > 1
+
+	// Transposition table lookup, PV nodes keep searching so the principal variation is not cut short
+	ttMove, shouldReturn, ttScore := s.tt.Query(p.Zobrist, depth, ply, alpha, beta)
+	if shouldReturn && !pvNode {
+		return ttScore
+	}
+
+	// Resolving captures before evaluating, so we do not stop in the middle of an exchange
+	if depth <= 0 {
+		return w.Quiescence(p, ply, alpha, beta)
+	}
+
+	staticEval := 0
+	if !inCheck {
+		staticEval = Evaluate(p)
+	}
+
+	// Reverse futility pruning
+	if features.ReverseFutilityPruning && !pvNode && !inCheck && depth <= ReverseFutilityMaxDepth && !IsMateScore(beta) &&
+		staticEval-ReverseFutilityMargin*depth >= beta {
+		return beta
+	}
+
+	// Null move pruning, never twice in a row and not without pieces, where zugzwang is common
+	if features.NullMovePruning && allowNull && !pvNode && !inCheck && depth >= NullMoveMinDepth && staticEval >= beta && hasNonPawnMaterial(p) {
+		reduction := NullMoveReduction + depth/6
+
+		p.MakeNullMove()
+		score := -w.NegaMax(p, max(depth-1-reduction, 0), ply+1, -beta, -beta+1, false)
+		p.UnmakeMove()
+
+		if w.aborted {
+			return 0
+		}
+		if score >= beta {
+			return beta
+		}
+	}
+
+	// Moves are generated lazily, a cutoff by the hash move does not generate any
+	picker := &w.movePickers[ply]
+	picker.Init(p, ttMove, w.killerMoves[ply], w.history)
+
+	// Futility pruning
+	canPruneQuiets := features.FutilityPruning && !pvNode && !inCheck && depth <= FutilityMaxDepth && !IsMateScore(alpha) &&
+		staticEval+FutilityMargin*depth <= alpha
+
+	// Alpha-Beta-Pruned search
+	var bestMove board.Move
+	currentEval := -Infinity
+
+	// Quiet moves searched so far, they are punished in the history if a later quiet move causes a cutoff
+	var searchedQuiets [MaxSearchedQuiets]board.Move
+	quietCount := 0
+
+	moveCount := 0
+	for m := picker.Next(); m != board.NullMove; m = picker.Next() {
+		i := moveCount
+		moveCount++
+
+		isQuiet := p.Pieces[m.To()] == 0 && !m.IsEnPassant() && !m.IsPromotion()
+
+		historyScore := 0
+		if isQuiet {
+			historyScore = w.history.Score(p, m)
+		}
+
+		p.MakeMove(m)
+		givesCheck := IsInCheck(p)
+
+		if canPruneQuiets && i > 0 && isQuiet && !givesCheck {
+			p.UnmakeMove()
+			continue
+		}
+
+		reduction := 0
+		if features.LateMoveReductions && depth >= LMRMinDepth && i >= LMRMinMoveIndex && isQuiet && !inCheck && !givesCheck {
+			reduction = lateMoveReduction(depth, i, pvNode, historyScore)
+		}
+
+		// min(a, b) = -max(-b, -a)
+		var score int
+		if i == 0 {
+			score = -w.NegaMax(p, depth-1, ply+1, -beta, -alpha, true)
+		} else {
+			searchBeta := beta
+			if features.PrincipalVariationSearch {
+				searchBeta = alpha + 1
+			}
+
+			score = -w.NegaMax(p, depth-1-reduction, ply+1, -searchBeta, -alpha, true)
+
+			// Reduced moves that beat alpha get the full depth, moves beating alpha in a zero window the full window
+			if score > alpha && reduction > 0 {
+				score = -w.NegaMax(p, depth-1, ply+1, -searchBeta, -alpha, true)
+			}
+			if score > alpha && score < beta && searchBeta != beta {
+				score = -w.NegaMax(p, depth-1, ply+1, -beta, -alpha, true)
+			}
+		}
+
+		p.UnmakeMove()
+
+		// The score of an aborted subtree is meaningless and must not reach the TT
+		if w.aborted {
+			return 0
+		}
+
+		if isQuiet && quietCount < MaxSearchedQuiets {
+			searchedQuiets[quietCount] = m
+			quietCount++
+		}
+
+		if score > currentEval {
+			currentEval = score
+			bestMove = m
+		}
+		if score > alpha {
+			w.updatePV(ply, m)
+		}
+		alpha = max(alpha, currentEval)
+
+		// Move is too good (killer move), opponent will play another move
+		if alpha >= beta {
+			if isQuiet {
+				if w.killerMoves[ply][0] != m {
+					w.killerMoves[ply][1] = w.killerMoves[ply][0]
+					w.killerMoves[ply][0] = m
+				}
+				w.history.Update(p, m, searchedQuiets[:quietCount], depth)
+			}
+			break
+		}
+	}
+
+	// Without legal moves the game is over, closer mates score higher for the winning side
+	if moveCount == 0 {
+		if inCheck {
+			return MatedIn(ply)
+		}
+		return DrawScore
+	}
+
+	// Storing in TT
+	s.tt.Store(p.Zobrist, depth, ply, currentEval, alpha0, beta, bestMove)
+
+	return alpha
+}
+
+// updatePV makes m followed by the principal variation of the child the new principal variation at this ply
+func (w *worker) updatePV(ply int, m board.Move) {
+	w.pv[ply][ply] = m
+	copy(w.pv[ply][ply+1:], w.pv[ply+1][ply+1:w.pvLength[ply+1]])
+	w.pvLength[ply] = w.pvLength[ply+1]
+}
+
+func (w *worker) shouldStop() bool {
+	if w.aborted {
+		return true
+	}
+
+	s := w.searcher
+
+	// Helpers are stopped by the main worker, which takes care of all limits
+	if !w.isMain() {
+		w.aborted = s.finished.Load() || s.signals.stopRequested.Load()
+		return w.aborted
+	}
+
+	// Always finish the first iteration, so there is a move to play
+	if w.completedDepth == 0 {
+		return false
+	}
+
+	if s.signals.stopRequested.Load() || (s.limits.Nodes > 0 && s.nodesSearched() >= s.limits.Nodes) {
+		w.aborted = true
+	}
+
+	// Reading the clock is expensive, so it is only done every 2048 nodes
+	if w.nodes.Load()&2047 == 0 {
+		s.checkPonderHit()
+		if s.timeManager.HardLimitReached() {
+			w.aborted = true
+		}
+	}
+
+	return w.aborted
+}
@@ -0,0 +1,174 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	A time control gives both sides a base time, which may be topped up again after a number of moves, and extra time for
+	every move. How the extra time is given depends on the mode:
+	- Fischer: the increment is added after every move
+	- Bronstein: the time used for the move is given back, up to the increment
+	- Delay: the clock only starts running once the delay has passed, also known as simple or US delay
+	A fixed time per move replaces all of that, every move gets the same time and unused time is not carried over.
+*/
+
+type TimeMode byte
+
+const (
+	Fischer TimeMode = iota
+	Bronstein
+	Delay
+)
+
+type TimeControl struct {
+	Base           time.Duration
+	Increment      time.Duration // Increment or delay, depending on the mode
+	Mode           TimeMode
+	MovesPerPeriod int           // The base time is added again after this many moves, 0 if there is only one period
+	PerMove        time.Duration // Fixed time for every move, the other fields are ignored if set
+}
+
+// A Clock is the time one side has left
+type Clock struct {
+	Remaining time.Duration
+	Moves     int // Moves made, to know when the next period starts
+}
+
+// ClockState is what a player is told about the clocks before a move
+type ClockState struct {
+	WhiteTime time.Duration
+	BlackTime time.Duration
+	Increment time.Duration // Added, given back or waited for every move, depending on the mode
+	MovesToGo int           // Moves until the next period, 0 if there is none
+	MoveTime  time.Duration // Fixed time for the move, the clocks do not matter if set
+}
+
+// A TimedPlayer is told the clocks before every move it makes in a timed game
+type TimedPlayer interface {
+	SetClockState(c ClockState)
+}
+
+// ParseTimeControl reads a time control in the format of the PGN TimeControl tag, like 300+2 or 40/5400+30, in seconds
+func ParseTimeControl(text string, mode TimeMode) (TimeControl, error) {
+	tc := TimeControl{Mode: mode}
+
+	period := text
+	if moves, rest, found := strings.Cut(text, "/"); found {
+		n, err := strconv.Atoi(moves)
+		if err != nil || n <= 0 {
+			return tc, fmt.Errorf("invalid number of moves in time control %s", text)
+		}
+		tc.MovesPerPeriod = n
+		period = rest
+	}
+
+	base, increment, hasIncrement := strings.Cut(period, "+")
+	var err error
+	if tc.Base, err = parseSeconds(base); err != nil || tc.Base <= 0 {
+		return tc, fmt.Errorf("invalid base time in time control %s", text)
+	}
+	if hasIncrement {
+		if tc.Increment, err = parseSeconds(increment); err != nil {
+			return tc, fmt.Errorf("invalid increment in time control %s", text)
+		}
+	}
+
+	return tc, nil
+}
+
+func parseSeconds(text string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid number of seconds: %s", text)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// PGNTag writes the time control for the PGN TimeControl tag, which has no way to express delays or a time per move
+func (tc TimeControl) PGNTag() (string, bool) {
+	if tc.PerMove > 0 || (tc.Mode != Fischer && tc.Increment > 0) {
+		return "", false
+	}
+
+	tag := strconv.FormatFloat(tc.Base.Seconds(), 'f', -1, 64)
+	if tc.MovesPerPeriod > 0 {
+		tag = fmt.Sprintf("%d/%s", tc.MovesPerPeriod, tag)
+	}
+	if tc.Increment > 0 {
+		tag += "+" + strconv.FormatFloat(tc.Increment.Seconds(), 'f', -1, 64)
+	}
+	return tag, true
+}
+
+func (tc TimeControl) startClock() Clock {
+	if tc.PerMove > 0 {
+		return Clock{Remaining: tc.PerMove}
+	}
+	return Clock{Remaining: tc.Base}
+}
+
+// charge takes the time of a move from the clock, and returns false if the time ran out before the move was made
+func (tc TimeControl) charge(c *Clock, elapsed time.Duration) bool {
+	c.Moves++
+
+	if tc.PerMove > 0 {
+		if elapsed > tc.PerMove {
+			c.Remaining = 0
+			return false
+		}
+		return true
+	}
+
+	used := elapsed
+	if tc.Mode == Delay {
+		used = max(elapsed-tc.Increment, 0)
+	}
+
+	c.Remaining -= used
+	if c.Remaining < 0 {
+		c.Remaining = 0
+		return false
+	}
+
+	switch tc.Mode {
+	case Fischer:
+		c.Remaining += tc.Increment
+	case Bronstein:
+		c.Remaining += min(elapsed, tc.Increment)
+	}
+
+	if tc.MovesPerPeriod > 0 && c.Moves%tc.MovesPerPeriod == 0 {
+		c.Remaining += tc.Base
+	}
+	return true
+}
+
+// state describes the clocks for the side to move, the moves to go depend on its clock
+func (tc TimeControl) state(white, black Clock, whiteToMove bool) ClockState {
+	if tc.PerMove > 0 {
+		return ClockState{MoveTime: tc.PerMove}
+	}
+
+	state := ClockState{WhiteTime: white.Remaining, BlackTime: black.Remaining, Increment: tc.Increment}
+	if tc.MovesPerPeriod > 0 {
+		moves := white.Moves
+		if !whiteToMove {
+			moves = black.Moves
+		}
+		state.MovesToGo = tc.MovesPerPeriod - moves%tc.MovesPerPeriod
+	}
+	return state
+}
+
+// formatClock writes the time left as m:ss, with tenths of a second once it gets short
+func formatClock(d time.Duration) string {
+	if d < 10*time.Second {
+		return fmt.Sprintf("0:%04.1f", d.Seconds())
+	}
+	seconds := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,136 @@
+package game
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/utils"
+	"fmt"
+	"strings"
+)
+
+/*
+	Players at the terminal may type commands instead of moves. The player only recognizes them and hands them to the game,
+	which carries them out and asks the same player for its move again afterward.
+*/
+
+type Command struct {
+	Name string
+	Args []string
+}
+
+// A CommandPlayer may answer with a command instead of a move, the command is empty when a move was played
+type CommandPlayer interface {
+	AwaitMoveOrCommand(p *board.Position, legalMoveTable *map[string]board.Move) (board.Move, Command)
+}
+
+// A DrawOfferHandler decides whether to accept a draw offered by its opponent, who is to move in the position
+type DrawOfferHandler interface {
+	AcceptsDraw(p *board.Position) bool
+}
+
+// Commands in the order help lists them
+var commands = []struct {
+	name, usage, description string
+}{
+	{"undo", "undo", "take back the last full move"},
+	{"resign", "resign", "give up the game"},
+	{"draw", "draw", "offer a draw to the opponent"},
+	{"flip", "flip", "turn the board around"},
+	{"fen", "fen", "show the FEN of the position"},
+	{"pgn", "pgn", "show the game so far as PGN"},
+	{"save", "save <file>", "write the game so far to a PGN file"},
+	{"help", "help", "list the commands"},
+}
+
+// ParseCommand checks whether the input is one of the game commands, rather than a move
+func ParseCommand(input string) (Command, bool) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return Command{}, false
+	}
+
+	name := strings.ToLower(fields[0])
+	for _, command := range commands {
+		if command.name == name {
+			return Command{Name: name, Args: fields[1:]}, true
+		}
+	}
+	return Command{}, false
+}
+
+// runCommand carries out a command of the player to move, and returns whether the game has to continue from the top of
+// the loop because the position or the board changed
+func (g *Game) runCommand(c Command) bool {
+	switch c.Name {
+	case "undo":
+		return g.undo()
+	case "resign":
+		g.end(board.Win(!g.currentPosition.WhiteToMove, board.Resignation))
+		return true
+	case "draw":
+		return g.offerDraw()
+	case "flip":
+		g.flipped = !g.flipped
+		return true
+	case "fen":
+		fmt.Println(utils.ToFEN(g.currentPosition))
+	case "pgn":
+		fmt.Println(g.PGN())
+	case "save":
+		if len(c.Args) != 1 {
+			fmt.Println("Usage: save <file>")
+			break
+		}
+		if err := g.SavePGN(c.Args[0]); err != nil {
+			fmt.Printf("Could not save the game: %v\n", err)
+		} else {
+			fmt.Printf("Saved the game to %s\n", c.Args[0])
+		}
+	case "help":
+		for _, command := range commands {
+			fmt.Printf("  %-12s %s\n", command.usage, command.description)
+		}
+	}
+	return false
+}
+
+// undo takes back the last move of both sides, so the same player is to move again
+func (g *Game) undo() bool {
+	const plies = 2
+	if len(g.record.Moves) < plies {
+		fmt.Println("There is no full move to take back")
+		return false
+	}
+
+	for range plies {
+		g.currentPosition = g.currentPosition.LastPos
+	}
+
+	// The time spent is not given back, but the moves no longer count toward the next period
+	for i := range g.clocks {
+		g.clocks[i].Moves = max(g.clocks[i].Moves-1, 0)
+	}
+	g.record.Moves = g.record.Moves[:len(g.record.Moves)-plies]
+	return true
+}
+
+// offerDraw asks the opponent of the player to move, players that cannot answer decline every offer. The time the
+// opponent takes to answer is not charged to the player who offered the draw
+func (g *Game) offerDraw() bool {
+	opponent := g.playerWhite
+	if g.currentPosition.WhiteToMove {
+		opponent = g.playerBlack
+	}
+
+	offered := g.Now()
+	handler, ok := opponent.(DrawOfferHandler)
+	accepted := ok && handler.AcceptsDraw(g.currentPosition)
+	g.moveStart = g.moveStart.Add(g.Now().Sub(offered))
+
+	if accepted {
+		g.end(board.DrawBy(board.Agreement))
+		return true
+	}
+
+	fmt.Println("The draw offer was declined")
+	return false
+}
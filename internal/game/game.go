@@ -3,8 +3,11 @@ package game
 import (
 	"endtner.dev/nChess/internal/board"
 	"endtner.dev/nChess/internal/engine"
+	"endtner.dev/nChess/internal/pgn"
 	"endtner.dev/nChess/internal/utils"
 	"fmt"
+	"os"
+	"time"
 )
 
 /*
@@ -22,40 +25,232 @@ type Game struct {
 	playerWhite     AbstractPlayer
 	playerBlack     AbstractPlayer
 	currentPosition *board.Position
+
+	record  *pgn.Game    // Every move played so far, for exporting the game
+	result  board.Result // Set once the game is over, by the rules or by the players
+	flipped bool         // Whether the board is shown from black's side
+
+	timeControl *TimeControl // Nil for a game without clocks
+	clocks      [2]Clock     // White first
+	moveStart   time.Time    // When the player to move was asked for its move
+
+	Now func() time.Time // Time source of the clocks
+
+	PGNFile string // The finished game is written to this file, if set
+}
+
+// An EvaluatingPlayer tells how it evaluated its last move, from its own point of view, which gets annotated in the PGN
+type EvaluatingPlayer interface {
+	LastEvaluation() (score int, found bool)
 }
 
 func NewGame(playerWhite AbstractPlayer, playerBlack AbstractPlayer) *Game {
-	return &Game{playerWhite: playerWhite, playerBlack: playerBlack, currentPosition: utils.FromFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")}
+	start := utils.FromFen(utils.StartPosition)
+
+	record := &pgn.Game{
+		Tags: map[string]string{
+			"Event": "nChess game",
+			"Site":  "?",
+			"Date":  time.Now().Format("2006.01.02"),
+			"Round": "-",
+			"White": playerName(playerWhite),
+			"Black": playerName(playerBlack),
+		},
+		Start:  start.Copy(),
+		Result: pgn.ResultUnknown,
+	}
+
+	return &Game{playerWhite: playerWhite, playerBlack: playerBlack, currentPosition: start, record: record, Now: time.Now}
+}
+
+// SetTimeControl plays the game with clocks, it has to be called before the game starts
+func (g *Game) SetTimeControl(tc TimeControl) {
+	g.timeControl = &tc
+	g.clocks = [2]Clock{tc.startClock(), tc.startClock()}
+
+	if tag, ok := tc.PGNTag(); ok {
+		g.record.Tags["TimeControl"] = tag
+	}
+}
+
+func playerName(player AbstractPlayer) string {
+	if player.GetPlayerType() == Engine {
+		return "nChess"
+	}
+	return "Human"
 }
 
 func (g *Game) RunGameLoop() {
 	g.playerWhite.Init()
 	g.playerBlack.Init()
 
-	for !g.currentPosition.IsTerminal {
-		utils.Display(g.currentPosition)
-
-		playerToMove := g.playerBlack
-		colorToMove := "Black"
-		if g.currentPosition.WhiteToMove {
-			playerToMove = g.playerWhite
-			colorToMove = "White"
+	for {
+		// Generating the moves also updates the terminal state of the position
+		legalMoves := engine.LegalMoves(g.currentPosition)
+		if g.currentPosition.IsTerminal() {
+			g.end(g.currentPosition.Result)
+		}
+		if g.result.IsOver() {
+			break
 		}
 
-		legalMoves := engine.LegalMoves(g.currentPosition)
+		g.display()
+		g.displayClocks()
+
 		legalMoveTable := make(map[string]board.Move)
 
 		for _, m := range legalMoves {
 			legalMoveTable[board.MoveToString(m)] = m
 		}
-		if playerToMove.GetPlayerType() == Human {
+
+		playerToMove := g.playerToMove()
+		if timedPlayer, ok := playerToMove.(TimedPlayer); ok && g.timeControl != nil {
+			timedPlayer.SetClockState(g.timeControl.state(g.clocks[0], g.clocks[1], g.currentPosition.WhiteToMove))
+		}
+
+		g.moveStart = g.Now()
+		playedMove := g.awaitMove(playerToMove, &legalMoveTable)
+		if playedMove == board.NullMove {
+			// A command changed the game, so the position has to be looked at again
+			continue
+		}
+
+		// The clock can only be checked once the move is in, a player that took too long loses even so
+		if g.timeControl != nil && !g.timeControl.charge(g.clockToMove(), g.Now().Sub(g.moveStart)) {
+			g.timeout()
+			continue
+		}
+		g.playMove(playerToMove, playedMove)
+	}
+	g.display()
+	fmt.Println()
+	fmt.Printf("Game terminated by: %s\n", g.result)
+
+	if g.PGNFile != "" {
+		if err := g.SavePGN(g.PGNFile); err != nil {
+			fmt.Printf("Could not save the game: %v\n", err)
+		}
+	}
+}
+
+func (g *Game) clockToMove() *Clock {
+	if g.currentPosition.WhiteToMove {
+		return &g.clocks[0]
+	}
+	return &g.clocks[1]
+}
+
+// timeout ends the game for the side to move, whose time ran out. It only loses if the opponent could still checkmate
+func (g *Game) timeout() {
+	whiteToMove := g.currentPosition.WhiteToMove
+
+	if g.currentPosition.HasMatingMaterial(!whiteToMove) {
+		g.end(board.Win(!whiteToMove, board.Timeout))
+	} else {
+		g.end(board.DrawBy(board.Timeout))
+	}
+}
+
+func (g *Game) playerToMove() AbstractPlayer {
+	if g.currentPosition.WhiteToMove {
+		return g.playerWhite
+	}
+	return g.playerBlack
+}
+
+// awaitMove asks the player for its move and runs the commands it gives in between. It returns the null move if a
+// command changed the game
+func (g *Game) awaitMove(player AbstractPlayer, legalMoveTable *map[string]board.Move) board.Move {
+	colorToMove := "Black"
+	if g.currentPosition.WhiteToMove {
+		colorToMove = "White"
+	}
+
+	commandPlayer, acceptsCommands := player.(CommandPlayer)
+	for {
+		if player.GetPlayerType() == Human {
 			fmt.Printf("[%s] Enter move: ", colorToMove)
 		} else {
 			fmt.Printf("[%s] Thinking...\n", colorToMove)
 		}
-		playedMove := playerToMove.AwaitMove(g.currentPosition, &legalMoveTable)
-		g.currentPosition = g.currentPosition.MakeMove(playedMove)
+
+		if !acceptsCommands {
+			return player.AwaitMove(g.currentPosition, legalMoveTable)
+		}
+
+		m, command := commandPlayer.AwaitMoveOrCommand(g.currentPosition, legalMoveTable)
+		if command.Name == "" {
+			return m
+		}
+		if g.runCommand(command) {
+			return board.NullMove
+		}
 	}
-	fmt.Println()
-	fmt.Printf("Game terminated by: %s\n", g.currentPosition.TerminalReason)
+}
+
+// end finishes the game, later results do not overwrite the first one
+func (g *Game) end(result board.Result) {
+	if g.result.IsOver() {
+		return
+	}
+	g.result = result
+	g.record.SetResult(result)
+}
+
+// Result tells who won the game and why, the winner is WinnerNone while the game is running
+func (g *Game) Result() board.Result {
+	return g.result
+}
+
+func (g *Game) displayClocks() {
+	if g.timeControl == nil {
+		return
+	}
+	fmt.Printf("White %s | Black %s\n", formatClock(g.clocks[0].Remaining), formatClock(g.clocks[1].Remaining))
+}
+
+func (g *Game) display() {
+	if g.flipped {
+		utils.DisplayFlipped(g.currentPosition)
+	} else {
+		utils.Display(g.currentPosition)
+	}
+}
+
+// playMove makes the move and adds it to the record of the game
+func (g *Game) playMove(player AbstractPlayer, m board.Move) {
+	next := g.currentPosition.MakeMoveCopy(m)
+
+	node := &pgn.Node{SAN: pgn.FormatSAN(g.currentPosition, m), Move: m, Position: next}
+	if g.timeControl != nil && g.timeControl.PerMove == 0 {
+		node.Clock = g.clockToMove().Remaining
+	}
+	if evaluatingPlayer, ok := player.(EvaluatingPlayer); ok {
+		if score, found := evaluatingPlayer.LastEvaluation(); found {
+			node.Eval = formatEval(score, g.currentPosition.WhiteToMove)
+		}
+	}
+	g.record.Moves = append(g.record.Moves, node)
+
+	g.currentPosition = next
+}
+
+// PGN returns the game so far in PGN export format
+func (g *Game) PGN() string {
+	return g.record.String()
+}
+
+func (g *Game) SavePGN(path string) error {
+	return os.WriteFile(path, []byte(g.PGN()), 0644)
+}
+
+// formatEval turns a score of the player who moved into white's point of view, in pawns or as mate
+func formatEval(score int, whiteMoved bool) string {
+	if !whiteMoved {
+		score = -score
+	}
+	if engine.IsMateScore(score) {
+		return fmt.Sprintf("#%d", engine.MateInMoves(score))
+	}
+	return fmt.Sprintf("%.2f", float64(score)/100)
 }
@@ -2,17 +2,39 @@ package players
 
 import (
 	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/book"
 	"endtner.dev/nChess/internal/engine"
 	"endtner.dev/nChess/internal/game"
 	"time"
 )
 
+const (
+	MoveTime            = 15 * time.Second // Time per move in games without clocks
+	DrawOfferSearchTime = 2 * time.Second  // Time the engine thinks about a draw offer
+	DrawAcceptanceScore = 0                // The engine takes a draw unless it evaluates its position above this, in centipawns
+)
+
 type EnginePlayer struct {
 	PlayerType byte
+	Book       *book.Book // Optional, consulted before searching
+	searcher   *engine.Searcher
+	lastInfo   *engine.SearchInfo // Best line of the last completed iteration, empty after a book move
+	clock      *game.ClockState   // Clocks before the move to make, empty in games without clocks
+}
+
+// NewEnginePlayer creates a player with its own searcher, so its transposition table is kept for the whole game
+func NewEnginePlayer() EnginePlayer {
+	e := EnginePlayer{PlayerType: game.Engine, searcher: engine.NewSearcher(), lastInfo: &engine.SearchInfo{}, clock: &game.ClockState{}}
+	e.searcher.OnIteration = func(info engine.SearchInfo) {
+		if info.MultiPV == 1 {
+			*e.lastInfo = info
+		}
+	}
+	return e
 }
 
 func (e EnginePlayer) Init() {
-	e.PlayerType = game.Engine
+	e.searcher.NewGame()
 }
 
 func (e EnginePlayer) GetPlayerType() byte {
@@ -20,5 +42,49 @@ func (e EnginePlayer) GetPlayerType() byte {
 }
 
 func (e EnginePlayer) AwaitMove(p *board.Position, legalMoveTable *map[string]board.Move) board.Move {
-	return engine.IterativeDeepeningSearch(p, 32, 15*time.Second)
+	*e.lastInfo = engine.SearchInfo{}
+
+	if e.Book != nil {
+		if m, found := e.Book.PickMove(p); found {
+			return m
+		}
+	}
+
+	return e.searcher.Search(p, e.searchLimits())
+}
+
+func (e EnginePlayer) SetClockState(c game.ClockState) {
+	*e.clock = c
+}
+
+// searchLimits budgets the search like a UCI engine would with the same clocks. Delays are treated like an increment,
+// since the time is not taken from the clock either way
+func (e EnginePlayer) searchLimits() engine.SearchLimits {
+	switch {
+	case e.clock.MoveTime > 0:
+		return engine.SearchLimits{MoveTime: e.clock.MoveTime}
+	case e.clock.WhiteTime > 0 || e.clock.BlackTime > 0:
+		return engine.SearchLimits{
+			WhiteTime:      e.clock.WhiteTime,
+			BlackTime:      e.clock.BlackTime,
+			WhiteIncrement: e.clock.Increment,
+			BlackIncrement: e.clock.Increment,
+			MovesToGo:      e.clock.MovesToGo,
+		}
+	default:
+		return engine.SearchLimits{MoveTime: MoveTime}
+	}
+}
+
+func (e EnginePlayer) LastEvaluation() (int, bool) {
+	return e.lastInfo.Score, e.lastInfo.Depth > 0
+}
+
+// AcceptsDraw searches the position briefly and takes the draw if the engine does not think it is ahead
+func (e EnginePlayer) AcceptsDraw(p *board.Position) bool {
+	*e.lastInfo = engine.SearchInfo{}
+	e.searcher.Search(p, engine.SearchLimits{MoveTime: DrawOfferSearchTime})
+
+	// The opponent is to move, so the score is from its point of view
+	return e.lastInfo.Depth > 0 && -e.lastInfo.Score <= DrawAcceptanceScore
 }
@@ -3,9 +3,12 @@ package players
 import (
 	"bufio"
 	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/engine"
 	"endtner.dev/nChess/internal/game"
+	"endtner.dev/nChess/internal/pgn"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 )
 
@@ -21,22 +24,63 @@ func (h HumanPlayer) GetPlayerType() byte {
 	return h.PlayerType
 }
 
+// Every read goes through the same buffer, so lines typed ahead are not lost between moves
+var stdin = bufio.NewReader(os.Stdin)
+
 func (h HumanPlayer) AwaitMove(p *board.Position, legalMoveTable *map[string]board.Move) board.Move {
-	reader := bufio.NewReader(os.Stdin)
-	text, err := reader.ReadString('\n')
+	for {
+		m, command := h.AwaitMoveOrCommand(p, legalMoveTable)
+		if command.Name == "" {
+			return m
+		}
+		fmt.Print("Commands are not available here. Enter a move: ")
+	}
+}
+
+// AwaitMoveOrCommand reads until a legal move or a game command is entered. Moves may be given in SAN (Nf3, exd5, O-O)
+// or in long algebraic notation (g1f3)
+func (h HumanPlayer) AwaitMoveOrCommand(p *board.Position, legalMoveTable *map[string]board.Move) (board.Move, game.Command) {
+	for {
+		text := readLine()
+
+		if command, found := game.ParseCommand(text); found {
+			return board.NullMove, command
+		}
+
+		m, err := pgn.ParseMoveInput(p, text)
+		if err == nil {
+			return m, game.Command{}
+		}
+
+		fmt.Println(err)
+		fmt.Println("Legal moves:", strings.Join(legalMovesSAN(p), " "))
+		fmt.Print("Enter a new move, or help for the commands: ")
+	}
+}
+
+// AcceptsDraw asks the player at the terminal, it is the opponent who offered the draw
+func (h HumanPlayer) AcceptsDraw(p *board.Position) bool {
+	fmt.Print("Your opponent offers a draw. Accept? [y/n]: ")
+	answer := strings.ToLower(strings.TrimSpace(readLine()))
+	return strings.HasPrefix(answer, "y")
+}
+
+func readLine() string {
+	text, err := stdin.ReadString('\n')
 	if err != nil {
 		fmt.Println("Error reading input:", err)
-		return h.AwaitMove(p, legalMoveTable)
+		os.Exit(1)
 	}
+	return text
+}
 
-	moveString := strings.TrimSpace(text)
-
-	if entry, found := (*legalMoveTable)[moveString]; found {
-		return entry
-	} else {
-		fmt.Printf("'%s'\n", moveString)
-		fmt.Println(*legalMoveTable)
-		fmt.Print("Illegal move. Enter a new move: ")
-		return h.AwaitMove(p, legalMoveTable)
+// legalMovesSAN lists the legal moves in SAN, sorted so that moves of the same piece are next to each other
+func legalMovesSAN(p *board.Position) []string {
+	moves := engine.LegalMoves(p)
+	sans := make([]string, len(moves))
+	for i, m := range moves {
+		sans[i] = pgn.FormatSAN(p, m)
 	}
+	slices.Sort(sans)
+	return sans
 }
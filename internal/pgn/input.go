@@ -0,0 +1,85 @@
+package pgn
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/engine"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+/*
+	Moves typed by a person are read more leniently than the SAN in a PGN file. Long algebraic notation (e2e4, e2-e4, e7e8q)
+	is accepted as well, and letters may be typed in any case. Since a lowercase b could name the bishop or the b-file, both
+	readings are tried and the input is only rejected as ambiguous if both of them are legal.
+*/
+
+var longAlgebraic = regexp.MustCompile(`^([a-h][1-8])[-x]?([a-h][1-8])=?([nbrq]?)$`)
+
+// ParseMoveInput reads a move in SAN or long algebraic notation, ignoring the case of the input where possible
+func ParseMoveInput(p *board.Position, input string) (board.Move, error) {
+	text := strings.TrimRight(strings.TrimSpace(input), "+#!?")
+	if text == "" {
+		return board.NullMove, fmt.Errorf("no move entered")
+	}
+
+	if parts := longAlgebraic.FindStringSubmatch(strings.ToLower(text)); parts != nil {
+		moveString := parts[1] + parts[2] + parts[3]
+		for _, m := range engine.LegalMoves(p) {
+			if board.MoveToString(m) == moveString {
+				return m, nil
+			}
+		}
+		return board.NullMove, fmt.Errorf("move %s is not legal in this position", moveString)
+	}
+
+	var matches []board.Move
+	var firstErr error
+	for _, candidate := range sanCandidates(text) {
+		m, err := ParseSAN(p, candidate)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !slices.Contains(matches, m) {
+			matches = append(matches, m)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return board.NullMove, firstErr
+	case 1:
+		return matches[0], nil
+	default:
+		return board.NullMove, fmt.Errorf("move %s is ambiguous, it could be %s or %s", input, FormatSAN(p, matches[0]), FormatSAN(p, matches[1]))
+	}
+}
+
+// sanCandidates lists the ways the input could have been meant in proper SAN, the input itself first
+func sanCandidates(text string) []string {
+	candidates := []string{text}
+
+	lower := strings.ToLower(text)
+	switch strings.ReplaceAll(lower, "0", "o") {
+	case "o-o", "o-o-o":
+		return append(candidates, strings.ToUpper(lower))
+	}
+
+	// Promotion pieces are uppercase in SAN, like the squares are lowercase
+	n := len(lower)
+	if n >= 3 && strings.ContainsRune("nbrq", rune(lower[n-1])) && (lower[n-2] == '=' || (lower[n-2] >= '1' && lower[n-2] <= '8')) {
+		lower = lower[:n-1] + strings.ToUpper(lower[n-1:])
+	}
+
+	// Read as a pawn move, and as a piece move if it starts with a piece letter
+	candidates = append(candidates, lower)
+	if strings.ContainsRune("nbrqk", rune(lower[0])) {
+		candidates = append(candidates, strings.ToUpper(lower[:1])+lower[1:])
+	}
+
+	return candidates
+}
@@ -0,0 +1,218 @@
+package pgn
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenType byte
+
+const (
+	tokenEOF tokenType = iota
+	tokenTag
+	tokenComment
+	tokenNAG
+	tokenVariationStart
+	tokenVariationEnd
+	tokenResult
+	tokenSAN
+)
+
+type token struct {
+	kind  tokenType
+	text  string // Tag name, comment, result or SAN
+	value string // Tag value
+	nag   int
+	line  int
+}
+
+// lexer splits PGN text into tokens, move numbers and escaped lines are dropped on the way
+type lexer struct {
+	r    *bufio.Reader
+	line int
+
+	atLineStart bool
+}
+
+func newLexer(r io.Reader) *lexer {
+	return &lexer{r: bufio.NewReader(r), line: 1, atLineStart: true}
+}
+
+func (l *lexer) readRune() (rune, error) {
+	c, _, err := l.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+
+	l.atLineStart = c == '\n'
+	if c == '\n' {
+		l.line++
+	}
+	return c, nil
+}
+
+// unreadRune may only be called once after readRune, and never for a newline
+func (l *lexer) unreadRune() {
+	l.r.UnreadRune()
+}
+
+func (l *lexer) next() (token, error) {
+	for {
+		atLineStart := l.atLineStart
+		c, err := l.readRune()
+		if err == io.EOF {
+			return token{kind: tokenEOF, line: l.line}, nil
+		}
+		if err != nil {
+			return token{}, err
+		}
+
+		line := l.line
+		switch {
+		case unicode.IsSpace(c):
+			continue
+		case c == '%' && atLineStart:
+			// Lines starting with % are escaped and ignored
+			l.readUntil('\n')
+			continue
+		case c == '[':
+			return l.readTag(line)
+		case c == '{':
+			comment, err := l.readUntil('}')
+			if err != nil {
+				return token{}, invalidGame(line, "unterminated comment")
+			}
+			return token{kind: tokenComment, text: strings.TrimSpace(comment), line: line}, nil
+		case c == ';':
+			comment, _ := l.readUntil('\n')
+			return token{kind: tokenComment, text: strings.TrimSpace(comment), line: line}, nil
+		case c == '(':
+			return token{kind: tokenVariationStart, line: line}, nil
+		case c == ')':
+			return token{kind: tokenVariationEnd, line: line}, nil
+		case c == '$':
+			digits := l.readSymbol()
+			nag, err := strconv.Atoi(digits)
+			if err != nil {
+				return token{}, invalidGame(line, "invalid NAG $%s", digits)
+			}
+			return token{kind: tokenNAG, nag: nag, line: line}, nil
+		}
+
+		l.unreadRune()
+		symbol := l.readSymbol()
+
+		switch symbol {
+		case ResultWhiteWins, ResultBlackWins, ResultDraw, ResultUnknown:
+			return token{kind: tokenResult, text: symbol, line: line}, nil
+		}
+
+		// Move numbers may be attached to the move, like 12.e4 or 12...e5
+		symbol = stripMoveNumber(symbol)
+		if symbol == "" {
+			continue
+		}
+
+		return token{kind: tokenSAN, text: symbol, line: line}, nil
+	}
+}
+
+func stripMoveNumber(symbol string) string {
+	digits := 0
+	for digits < len(symbol) && unicode.IsDigit(rune(symbol[digits])) {
+		digits++
+	}
+
+	// Castling written with zeros also starts with a digit
+	if digits == len(symbol) || symbol[digits] != '.' {
+		if digits > 0 && digits == len(symbol) {
+			return ""
+		}
+		return symbol
+	}
+
+	return strings.TrimLeft(symbol[digits:], ".")
+}
+
+// readUntil reads up to the delimiter, which is consumed but not returned
+func (l *lexer) readUntil(delimiter rune) (string, error) {
+	var text strings.Builder
+	for {
+		c, err := l.readRune()
+		if err != nil {
+			return text.String(), err
+		}
+		if c == delimiter {
+			return text.String(), nil
+		}
+		text.WriteRune(c)
+	}
+}
+
+// readSymbol reads until the next whitespace or character with a meaning of its own
+func (l *lexer) readSymbol() string {
+	var symbol strings.Builder
+	for {
+		c, _, err := l.r.ReadRune()
+		if err != nil {
+			return symbol.String()
+		}
+		if unicode.IsSpace(c) || strings.ContainsRune("[]{}();$", c) {
+			l.r.UnreadRune()
+			return symbol.String()
+		}
+		symbol.WriteRune(c)
+	}
+}
+
+// readTag reads a tag pair like [Event "Casual Game"], the opening bracket is already read
+func (l *lexer) readTag(line int) (token, error) {
+	l.skipSpaces()
+	name := l.readSymbol()
+	l.skipSpaces()
+
+	c, err := l.readRune()
+	if err != nil || c != '"' {
+		return token{}, invalidGame(line, "tag %s has no quoted value", name)
+	}
+
+	var value strings.Builder
+	for {
+		c, err := l.readRune()
+		if err != nil {
+			return token{}, invalidGame(line, "unterminated tag %s", name)
+		}
+		if c == '"' {
+			break
+		}
+		if c == '\\' {
+			if c, err = l.readRune(); err != nil {
+				return token{}, invalidGame(line, "unterminated tag %s", name)
+			}
+		}
+		value.WriteRune(c)
+	}
+
+	l.skipSpaces()
+	if c, err := l.readRune(); err != nil || c != ']' {
+		return token{}, invalidGame(line, "tag %s is not closed", name)
+	}
+
+	return token{kind: tokenTag, text: name, value: value.String(), line: line}, nil
+}
+
+func (l *lexer) skipSpaces() {
+	for {
+		c, err := l.readRune()
+		if err != nil {
+			return
+		}
+		if !unicode.IsSpace(c) {
+			l.unreadRune()
+			return
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package pgn
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/utils"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+/*
+	The Reader reads the games of a PGN file one after another. Every move of the movetext, variations included, is
+	resolved against the legal moves of its position, so a game that could be read is a valid game.
+*/
+
+const (
+	ResultWhiteWins = "1-0"
+	ResultBlackWins = "0-1"
+	ResultDraw      = "1/2-1/2"
+	ResultUnknown   = "*"
+)
+
+type Game struct {
+	Tags    map[string]string
+	Start   *board.Position // Start position, taken from the FEN tag if there is one
+	Comment string          // Comment before the first move
+	Moves   []*Node         // Main line
+	Result  string
+}
+
+// Node is a move of the movetext with everything annotated to it
+type Node struct {
+	SAN        string // As written in the file, without suffix annotations like !?
+	Move       board.Move
+	Position   *board.Position // Position after the move
+	NAGs       []int
+	Comment    string
+	Variations [][]*Node // Alternatives to this move, played from the position before it
+
+	// Only used when writing, as [%clk] and [%eval] commands in front of the comment
+	Clock time.Duration // Time left for the player after the move
+	Eval  string        // From white's point of view, in pawns like 0.35 or as mate like #-3
+}
+
+// Positions returns the start position followed by the positions after each move of the main line
+func (g *Game) Positions() []*board.Position {
+	positions := []*board.Position{g.Start}
+	for _, node := range g.Moves {
+		positions = append(positions, node.Position)
+	}
+	return positions
+}
+
+// InvalidGameError is returned for a game that could not be read, the reader can still continue with the next game
+type InvalidGameError struct {
+	Line   int
+	Reason string
+}
+
+func (e *InvalidGameError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+func invalidGame(line int, format string, a ...any) error {
+	return &InvalidGameError{Line: line, Reason: fmt.Sprintf(format, a...)}
+}
+
+type Reader struct {
+	lexer  *lexer
+	peeked *token
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{lexer: newLexer(r)}
+}
+
+func (r *Reader) nextToken() (token, error) {
+	if r.peeked != nil {
+		t := *r.peeked
+		r.peeked = nil
+		return t, nil
+	}
+	return r.lexer.next()
+}
+
+func (r *Reader) unread(t token) {
+	r.peeked = &t
+}
+
+// Next returns the next game of the file, io.EOF once there are no more games. After an invalid game the reader
+// continues with the game after it.
+func (r *Reader) Next() (*Game, error) {
+	game := &Game{Tags: make(map[string]string), Result: ResultUnknown}
+
+	fenLine := 0
+	t, err := r.nextToken()
+	for ; err == nil && t.kind == tokenTag; t, err = r.nextToken() {
+		game.Tags[t.text] = t.value
+		if t.text == "FEN" {
+			fenLine = t.line
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.kind == tokenEOF && len(game.Tags) == 0 {
+		return nil, io.EOF
+	}
+	r.unread(t)
+
+	if result, found := game.Tags["Result"]; found {
+		game.Result = result
+	}
+
+	game.Start = utils.FromFen(utils.StartPosition)
+	if fen, found := game.Tags["FEN"]; found {
+		if err := utils.ValidateFEN(fen); err != nil {
+			r.skipGame()
+			return nil, invalidGame(fenLine, "invalid FEN: %v", err)
+		}
+		game.Start = utils.FromFen(fen)
+	}
+
+	game.Moves, err = r.parseLine(game, game.Start, 0)
+	if err != nil {
+		r.skipGame()
+		return nil, err
+	}
+
+	return game, nil
+}
+
+// parseLine reads the moves played from p, until the end of the variation or the game
+func (r *Reader) parseLine(game *Game, p *board.Position, depth int) ([]*Node, error) {
+	var line []*Node
+
+	for {
+		t, err := r.nextToken()
+		if err != nil {
+			return nil, err
+		}
+
+		var last *Node
+		if len(line) > 0 {
+			last = line[len(line)-1]
+		}
+
+		switch t.kind {
+		case tokenSAN:
+			// En passant captures may be marked separately
+			if t.text == "e.p." {
+				continue
+			}
+
+			san, nag := splitSuffixAnnotation(t.text)
+
+			position := p
+			if last != nil {
+				position = last.Position
+			}
+
+			m, err := ParseSAN(position, san)
+			if err != nil {
+				return nil, invalidGame(t.line, "%v", err)
+			}
+
+			node := &Node{SAN: san, Move: m, Position: position.MakeMoveCopy(m)}
+			if nag != 0 {
+				node.NAGs = append(node.NAGs, nag)
+			}
+			line = append(line, node)
+		case tokenNAG:
+			if last != nil {
+				last.NAGs = append(last.NAGs, t.nag)
+			}
+		case tokenComment:
+			switch {
+			case last != nil:
+				last.Comment = joinComments(last.Comment, t.text)
+			case depth == 0:
+				game.Comment = joinComments(game.Comment, t.text)
+			}
+		case tokenVariationStart:
+			if last == nil {
+				return nil, invalidGame(t.line, "variation without a move to replace")
+			}
+
+			before := p
+			if len(line) > 1 {
+				before = line[len(line)-2].Position
+			}
+
+			variation, err := r.parseLine(game, before, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			last.Variations = append(last.Variations, variation)
+		case tokenVariationEnd:
+			if depth == 0 {
+				return nil, invalidGame(t.line, "closing a variation that was never opened")
+			}
+			return line, nil
+		case tokenResult, tokenTag, tokenEOF:
+			if depth > 0 {
+				return nil, invalidGame(t.line, "unterminated variation")
+			}
+
+			// A tag means the next game starts, the result of this one is missing
+			if t.kind == tokenResult {
+				game.Result = t.text
+			} else {
+				r.unread(t)
+			}
+			return line, nil
+		}
+	}
+}
+
+// skipGame drops the tokens up to the end of the current game
+func (r *Reader) skipGame() {
+	for {
+		t, err := r.nextToken()
+		if err != nil || t.kind == tokenResult || t.kind == tokenEOF {
+			return
+		}
+		if t.kind == tokenTag {
+			r.unread(t)
+			return
+		}
+	}
+}
+
+var suffixAnnotations = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+// splitSuffixAnnotation separates annotations like e4!? from the move, returning them as NAG
+func splitSuffixAnnotation(san string) (string, int) {
+	move := strings.TrimRight(san, "!?")
+	return move, suffixAnnotations[san[len(move):]]
+}
+
+func joinComments(comment, addition string) string {
+	if comment == "" {
+		return addition
+	}
+	return comment + " " + addition
+}
@@ -0,0 +1,171 @@
+package pgn
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/engine"
+	"fmt"
+	"strings"
+)
+
+/*
+	SAN (standard algebraic notation) names the moved piece and its target square, like Nf3 or exd5. The start square is
+	only given as far as needed to tell two pieces apart (Nbd7, R1e2), pawn moves leave out the piece and promotions name
+	the new piece (e8=Q). Castling is written O-O and O-O-O.
+*/
+
+func ParseSAN(p *board.Position, san string) (board.Move, error) {
+	// Check and mate markers, annotations and en passant markers do not change the move
+	text := strings.TrimRight(strings.TrimSuffix(san, "e.p."), "+#!?")
+
+	var matches []board.Move
+	for _, m := range engine.LegalMoves(p) {
+		if matchesSAN(p, m, text) {
+			matches = append(matches, m)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return board.NullMove, fmt.Errorf("move %s is not legal in this position", san)
+	case 1:
+		return matches[0], nil
+	default:
+		candidates := make([]string, len(matches))
+		for i, m := range matches {
+			candidates[i] = FormatSAN(p, m)
+		}
+		return board.NullMove, fmt.Errorf("move %s is ambiguous, it could be %s", san, strings.Join(candidates, " or "))
+	}
+}
+
+func matchesSAN(p *board.Position, m board.Move, san string) bool {
+	switch strings.ReplaceAll(san, "0", "O") {
+	case "O-O":
+		return m.IsCastle() && m.To() > m.From()
+	case "O-O-O":
+		return m.IsCastle() && m.To() < m.From()
+	}
+	if m.IsCastle() {
+		return false
+	}
+
+	// Piece letter, nothing for pawns
+	pieceType := board.Pawn
+	if len(san) > 0 && strings.ContainsRune("NBRQK", rune(san[0])) {
+		pieceType = board.Value(rune(san[0])) & 0b00111
+		san = san[1:]
+	}
+	if p.Pieces[m.From()]&0b00111 != pieceType {
+		return false
+	}
+
+	// Promotion piece, with or without the equals sign
+	promotionType := uint8(0)
+	if len(san) > 0 && strings.ContainsRune("NBRQ", rune(san[len(san)-1])) {
+		promotionType = board.Value(rune(san[len(san)-1])) & 0b00111
+		san = strings.TrimSuffix(san[:len(san)-1], "=")
+	}
+	if m.IsPromotion() != (promotionType != 0) || (m.IsPromotion() && m.PromotionType() != promotionType) {
+		return false
+	}
+
+	// Target square
+	if len(san) < 2 || san[len(san)-2:] != board.IndexToSquare(m.To()) {
+		return false
+	}
+
+	// Whatever is left disambiguates the start square, pawns only name their file when capturing
+	from := m.From()
+	prefix := san[:len(san)-2]
+	if pieceType == board.Pawn && prefix != "" && !strings.HasSuffix(prefix, "x") {
+		return false
+	}
+	for _, c := range strings.TrimSuffix(prefix, "x") {
+		switch {
+		case c >= 'a' && c <= 'h':
+			if from%8 != int(c-'a') {
+				return false
+			}
+		case c >= '1' && c <= '8':
+			if from/8 != int(c-'1') {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// FormatSAN writes the move in SAN, with a check or mate marker. The position is only changed temporarily.
+func FormatSAN(p *board.Position, m board.Move) string {
+	var san strings.Builder
+
+	from, to := m.From(), m.To()
+	pieceType := p.Pieces[from] & 0b00111
+	isCapture := p.Pieces[to] != 0 || m.IsEnPassant()
+
+	switch {
+	case m.IsCastle() && to > from:
+		san.WriteString("O-O")
+	case m.IsCastle():
+		san.WriteString("O-O-O")
+	case pieceType == board.Pawn:
+		if isCapture {
+			san.WriteString(board.IndexToSquare(from)[:1] + "x")
+		}
+		san.WriteString(board.IndexToSquare(to))
+		if m.IsPromotion() {
+			san.WriteString("=" + board.ToString(m.PromotionType()))
+		}
+	default:
+		// Piece types without color are written like white pieces, in uppercase
+		san.WriteString(board.ToString(pieceType))
+		san.WriteString(disambiguation(p, m))
+		if isCapture {
+			san.WriteString("x")
+		}
+		san.WriteString(board.IndexToSquare(to))
+	}
+
+	p.MakeMove(m)
+	if engine.IsInCheck(p) {
+		if len(engine.LegalMoves(p)) == 0 {
+			san.WriteString("#")
+		} else {
+			san.WriteString("+")
+		}
+	}
+	p.UnmakeMove()
+
+	return san.String()
+}
+
+// disambiguation names as much of the start square as needed to tell the move apart from moves of the same kind of piece
+func disambiguation(p *board.Position, m board.Move) string {
+	from := m.From()
+	sameFile, sameRank, ambiguous := false, false, false
+
+	for _, other := range engine.LegalMoves(p) {
+		if other.To() != m.To() || other.From() == from || p.Pieces[other.From()] != p.Pieces[from] {
+			continue
+		}
+
+		ambiguous = true
+		sameFile = sameFile || other.From()%8 == from%8
+		sameRank = sameRank || other.From()/8 == from/8
+	}
+
+	square := board.IndexToSquare(from)
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return square[:1]
+	case !sameRank:
+		return square[1:]
+	default:
+		return square
+	}
+}
@@ -0,0 +1,202 @@
+package pgn
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/utils"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+const maxLineLength = 80 // Export format limit for movetext lines
+
+// The Seven Tag Roster comes first in every exported game, in this order
+var SevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// Write writes the game in PGN export format
+func Write(w io.Writer, g *Game) error {
+	_, err := io.WriteString(w, g.String())
+	return err
+}
+
+// SetResult records how the game ended, in the result of the movetext and in the Termination tag
+func (g *Game) SetResult(r board.Result) {
+	g.Result = ResultTag(r.Winner)
+	if r.IsOver() {
+		g.Tags["Termination"] = terminationTag(r.Reason)
+	}
+}
+
+// ResultTag gives the result as it is written in the Result tag and at the end of the movetext
+func ResultTag(winner board.Winner) string {
+	switch winner {
+	case board.WinnerWhite:
+		return ResultWhiteWins
+	case board.WinnerBlack:
+		return ResultBlackWins
+	case board.WinnerDraw:
+		return ResultDraw
+	default:
+		return ResultUnknown
+	}
+}
+
+// terminationTag uses the values the PGN standard suggests for the Termination tag, games ended by the rules or the
+// players ended normally
+func terminationTag(reason board.Reason) string {
+	switch reason {
+	case board.Timeout:
+		return "time forfeit"
+	case board.Adjudication:
+		return "adjudication"
+	default:
+		return "normal"
+	}
+}
+
+func (g *Game) String() string {
+	var text strings.Builder
+
+	for _, tag := range g.exportTags() {
+		value := strings.ReplaceAll(strings.ReplaceAll(tag[1], `\`, `\\`), `"`, `\"`)
+		fmt.Fprintf(&text, "[%s \"%s\"]\n", tag[0], value)
+	}
+	text.WriteString("\n")
+
+	var words []string
+	if g.Comment != "" {
+		words = append(words, commentWords(g.Comment)...)
+	}
+	words = appendLine(words, g.Moves, g.Start, false)
+	words = append(words, g.Result)
+
+	// Wrapping the movetext between words
+	lineLength := 0
+	for _, word := range words {
+		if lineLength > 0 && lineLength+1+len(word) > maxLineLength {
+			text.WriteString("\n")
+			lineLength = 0
+		}
+		if lineLength > 0 {
+			text.WriteString(" ")
+			lineLength++
+		}
+		text.WriteString(word)
+		lineLength += len(word)
+	}
+	text.WriteString("\n\n")
+
+	return text.String()
+}
+
+// exportTags returns the Seven Tag Roster, filled with placeholders where needed, followed by all other tags by name
+func (g *Game) exportTags() [][2]string {
+	tags := make(map[string]string, len(g.Tags)+2)
+	for name, value := range g.Tags {
+		tags[name] = value
+	}
+
+	tags["Result"] = g.Result
+	if g.Start != nil && utils.ToFEN(g.Start) != utils.StartPosition {
+		tags["SetUp"] = "1"
+		tags["FEN"] = utils.ToFEN(g.Start)
+	}
+
+	var exported [][2]string
+	for _, name := range SevenTagRoster {
+		value, found := tags[name]
+		if !found {
+			value = "?"
+			if name == "Date" {
+				value = "????.??.??"
+			}
+		}
+		exported = append(exported, [2]string{name, value})
+	}
+
+	var others []string
+	for name := range tags {
+		if !slices.Contains(SevenTagRoster, name) {
+			others = append(others, name)
+		}
+	}
+	slices.Sort(others)
+	for _, name := range others {
+		exported = append(exported, [2]string{name, tags[name]})
+	}
+
+	return exported
+}
+
+// appendLine adds the words of the moves played from p, black moves get a move number after anything interrupting
+func appendLine(words []string, line []*Node, p *board.Position, numberBlackMove bool) []string {
+	before := p
+	for i, node := range line {
+		if before.WhiteToMove {
+			words = append(words, fmt.Sprintf("%d.", before.FullMoves))
+		} else if i == 0 || numberBlackMove {
+			words = append(words, fmt.Sprintf("%d...", before.FullMoves))
+		}
+		numberBlackMove = false
+
+		words = append(words, node.SAN)
+		for _, nag := range node.NAGs {
+			words = append(words, fmt.Sprintf("$%d", nag))
+		}
+
+		if comment := node.exportComment(); comment != "" {
+			words = append(words, commentWords(comment)...)
+			numberBlackMove = true
+		}
+
+		for _, variation := range node.Variations {
+			variationWords := appendLine(nil, variation, before, true)
+			if len(variationWords) == 0 {
+				continue
+			}
+
+			variationWords[0] = "(" + variationWords[0]
+			variationWords[len(variationWords)-1] += ")"
+			words = append(words, variationWords...)
+			numberBlackMove = true
+		}
+
+		before = node.Position
+	}
+
+	return words
+}
+
+// exportComment puts the clock and evaluation commands in front of the comment
+func (n *Node) exportComment() string {
+	var parts []string
+	if n.Clock > 0 {
+		parts = append(parts, fmt.Sprintf("[%%clk %s]", formatClock(n.Clock)))
+	}
+	if n.Eval != "" {
+		parts = append(parts, fmt.Sprintf("[%%eval %s]", n.Eval))
+	}
+	if n.Comment != "" {
+		parts = append(parts, n.Comment)
+	}
+	return strings.Join(parts, " ")
+}
+
+// commentWords splits a comment into words for wrapping, a closing brace can not be part of a comment
+func commentWords(comment string) []string {
+	words := strings.Fields(strings.ReplaceAll(comment, "}", ")"))
+	if len(words) == 0 {
+		return nil
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	return words
+}
+
+// formatClock writes a duration as h:mm:ss
+func formatClock(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
@@ -5,6 +5,8 @@ import (
 	"endtner.dev/nChess/internal/engine"
 	"endtner.dev/nChess/internal/utils"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,11 +15,181 @@ import (
 	This will handle the commands for the UCI Engine. Possibly will be refactored
 */
 
-func (e *UCIEngine) handleGo() error {
-	fmt.Printf("bestmove %s\n", board.MoveToString(engine.IterativeDeepeningSearch(e.currentPos, 32, 10*time.Second)))
+func (e *UCIEngine) handleGo(args []string) error {
+	if e.currentPos == nil {
+		return fmt.Errorf("no position set")
+	}
+
+	limits, err := ParseGoLimits(e.currentPos, args)
+	if err != nil {
+		return err
+	}
+
+	// Book moves are played right away, unless the GUI asked for an analysis
+	if e.ownBook && e.book != nil && !limits.Infinite && !limits.Ponder && len(limits.SearchMoves) == 0 {
+		if m, found := e.book.PickMove(e.currentPos); found {
+			e.send("info string book move")
+			e.send("bestmove %s", uciMove(m))
+			return nil
+		}
+	}
+
+	searcher := e.searcher
+
+	// Searching in the background keeps the command loop responsive for stop, ponderhit and isready
+	result := searcher.Start(e.currentPos, limits)
+	searchDone := make(chan struct{})
+	e.searchDone = searchDone
+
+	go func() {
+		defer close(searchDone)
+
+		bestMove := <-result
+		searcher.WaitForRelease()
+
+		// The second move of the principal variation is the reply we expect, GUIs may let us ponder on it
+		pv := searcher.PrincipalVariation()
+		if len(pv) >= 2 && pv[0] == bestMove {
+			e.send("bestmove %s ponder %s", uciMove(bestMove), uciMove(pv[1]))
+		} else {
+			e.send("bestmove %s", uciMove(bestMove))
+		}
+	}()
+
 	return nil
 }
 
+// uciMove writes a move for the GUI, which expects 0000 instead of a move when there is none
+func uciMove(m board.Move) string {
+	if m == board.NullMove {
+		return "0000"
+	}
+	return board.MoveToString(m)
+}
+
+func (e *UCIEngine) sendSearchInfo(info engine.SearchInfo) {
+	pv := make([]string, len(info.PV))
+	for i, m := range info.PV {
+		pv[i] = board.MoveToString(m)
+	}
+
+	score := fmt.Sprintf("cp %d", info.Score)
+	if engine.IsMateScore(info.Score) {
+		score = fmt.Sprintf("mate %d", engine.MateInMoves(info.Score))
+	}
+
+	e.send("info multipv %d depth %d seldepth %d score %s nodes %d nps %d time %d hashfull %d pv %s",
+		info.MultiPV, info.Depth, info.SelDepth, score, info.Nodes, info.NodesPerSecond(), info.Time.Milliseconds(), info.HashFull, strings.Join(pv, " "))
+}
+
+func (e *UCIEngine) sendCurrentMove(info engine.CurrentMoveInfo) {
+	e.send("info depth %d currmove %s currmovenumber %d", info.Depth, board.MoveToString(info.Move), info.Number)
+}
+
+// ParseGoLimits reads the parameters of a go command, the moves after searchmoves have to be legal in p
+func ParseGoLimits(p *board.Position, args []string) (engine.SearchLimits, error) {
+	limits := engine.SearchLimits{}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "infinite":
+			limits.Infinite = true
+			continue
+		case "ponder":
+			limits.Ponder = true
+			continue
+		case "searchmoves":
+			// All following tokens are moves, until the next keyword
+			for i+1 < len(args) && !isGoKeyword(args[i+1]) {
+				i++
+				m, found := findMove(p, args[i])
+				if !found {
+					return limits, fmt.Errorf("move %s not possible", args[i])
+				}
+				limits.SearchMoves = append(limits.SearchMoves, m)
+			}
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return limits, fmt.Errorf("missing value for %s", args[i])
+		}
+
+		value, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err != nil {
+			return limits, fmt.Errorf("invalid value for %s: %s", args[i], args[i+1])
+		}
+		i++
+
+		switch args[i-1] {
+		case "wtime":
+			limits.WhiteTime = time.Duration(value) * time.Millisecond
+		case "btime":
+			limits.BlackTime = time.Duration(value) * time.Millisecond
+		case "winc":
+			limits.WhiteIncrement = time.Duration(value) * time.Millisecond
+		case "binc":
+			limits.BlackIncrement = time.Duration(value) * time.Millisecond
+		case "movestogo":
+			limits.MovesToGo = int(value)
+		case "movetime":
+			limits.MoveTime = time.Duration(value) * time.Millisecond
+		case "depth":
+			limits.Depth = int(value)
+		case "nodes":
+			limits.Nodes = value
+		case "mate":
+			limits.Mate = int(value)
+		default:
+			return limits, fmt.Errorf("unknown go parameter: %s", args[i-1])
+		}
+	}
+
+	return limits, nil
+}
+
+func isGoKeyword(token string) bool {
+	switch token {
+	case "searchmoves", "ponder", "wtime", "btime", "winc", "binc", "movestogo", "depth", "nodes", "mate", "movetime", "infinite":
+		return true
+	}
+	return false
+}
+
+func findMove(p *board.Position, moveStr string) (board.Move, bool) {
+	for _, m := range engine.LegalMoves(p) {
+		if board.MoveToString(m) == moveStr {
+			return m, true
+		}
+	}
+	return board.NullMove, false
+}
+
+func (e *UCIEngine) handleSetOption(args []string) error {
+	// Both the name and the value may contain spaces: setoption name <id> [value <x>]
+	if len(args) < 2 || args[0] != "name" {
+		return fmt.Errorf("invalid setoption command")
+	}
+
+	nameEnd := slices.Index(args, "value")
+	if nameEnd == -1 {
+		nameEnd = len(args)
+	}
+
+	name := strings.Join(args[1:nameEnd], " ")
+	value := ""
+	if nameEnd < len(args) {
+		value = strings.Join(args[nameEnd+1:], " ")
+	}
+
+	option, found := e.options.Find(name)
+	if !found {
+		return fmt.Errorf("unknown option: %s", name)
+	}
+
+	return option.Set(value)
+}
+
 func (e *UCIEngine) handlePosition(args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("invalid position command")
@@ -39,23 +211,15 @@ func (e *UCIEngine) handlePosition(args []string) error {
 
 	if len(args) > 0 && args[0] == "moves" {
 		for _, moveStr := range args[1:] {
-			legalMoves := engine.LegalMoves(e.currentPos)
-
-			moveFound := false
-			for _, m := range legalMoves {
-				if board.MoveToString(m) == moveStr {
-					moveFound = true
-					e.currentPos = e.currentPos.MakeMove(m)
-					break
-				}
-			}
-			if !moveFound {
+			m, found := findMove(e.currentPos, moveStr)
+			if !found {
 				return fmt.Errorf("move %s not possible", moveStr)
 			}
+			e.currentPos = e.currentPos.MakeMoveCopy(m)
 		}
 	}
 
-	fmt.Printf("info current position: %s\n", utils.ToFEN(e.currentPos))
+	e.send("info string current position: %s", utils.ToFEN(e.currentPos))
 
 	return nil
 }
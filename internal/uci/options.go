@@ -0,0 +1,141 @@
+package uci
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	Options the engine advertises on 'uci' and accepts through 'setoption name <id> [value <x>]'
+*/
+
+type Option interface {
+	Name() string
+	Declaration() string // The part after 'option name <id>'
+	Set(value string) error
+}
+
+type SpinOption struct {
+	name     string
+	Default  int
+	Min      int
+	Max      int
+	OnChange func(int)
+}
+
+func (o *SpinOption) Name() string {
+	return o.name
+}
+
+func (o *SpinOption) Declaration() string {
+	return fmt.Sprintf("type spin default %d min %d max %d", o.Default, o.Min, o.Max)
+}
+
+func (o *SpinOption) Set(value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %s", o.name, value)
+	}
+	if v < o.Min || v > o.Max {
+		return fmt.Errorf("value for %s out of range [%d, %d]: %d", o.name, o.Min, o.Max, v)
+	}
+
+	o.OnChange(v)
+	return nil
+}
+
+type CheckOption struct {
+	name     string
+	Default  bool
+	OnChange func(bool)
+}
+
+func (o *CheckOption) Name() string {
+	return o.name
+}
+
+func (o *CheckOption) Declaration() string {
+	return fmt.Sprintf("type check default %t", o.Default)
+}
+
+func (o *CheckOption) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %s", o.name, value)
+	}
+
+	o.OnChange(v)
+	return nil
+}
+
+type StringOption struct {
+	name     string
+	Default  string
+	OnChange func(string)
+}
+
+func (o *StringOption) Name() string {
+	return o.name
+}
+
+func (o *StringOption) Declaration() string {
+	// UCI uses the special value <empty> for empty strings
+	if o.Default == "" {
+		return "type string default <empty>"
+	}
+	return fmt.Sprintf("type string default %s", o.Default)
+}
+
+func (o *StringOption) Set(value string) error {
+	if value == "<empty>" {
+		value = ""
+	}
+
+	o.OnChange(value)
+	return nil
+}
+
+type ButtonOption struct {
+	name    string
+	OnPress func()
+}
+
+func (o *ButtonOption) Name() string {
+	return o.name
+}
+
+func (o *ButtonOption) Declaration() string {
+	return "type button"
+}
+
+func (o *ButtonOption) Set(string) error {
+	o.OnPress()
+	return nil
+}
+
+// OptionRegistry keeps the options in the order they were registered, names are matched case-insensitively
+type OptionRegistry struct {
+	options []Option
+}
+
+func (r *OptionRegistry) Register(o Option) {
+	r.options = append(r.options, o)
+}
+
+func (r *OptionRegistry) Find(name string) (Option, bool) {
+	for _, o := range r.options {
+		if strings.EqualFold(o.Name(), name) {
+			return o, true
+		}
+	}
+	return nil, false
+}
+
+func (r *OptionRegistry) Declarations() []string {
+	declarations := make([]string, len(r.options))
+	for i, o := range r.options {
+		declarations[i] = fmt.Sprintf("option name %s %s", o.Name(), o.Declaration())
+	}
+	return declarations
+}
@@ -3,30 +3,101 @@ package uci
 import (
 	"bufio"
 	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/book"
+	"endtner.dev/nChess/internal/engine"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 type UCIEngine struct {
 	currentPos *board.Position
+
+	options  OptionRegistry
+	searcher *engine.Searcher
+
+	ownBook bool
+	book    *book.Book // Nil as long as no book file is loaded
+
+	// Closed once the bestmove of the running search has been sent, nil when idle
+	searchDone chan struct{}
+
+	Output      io.Writer // Where the responses to the GUI go, stdout unless replaced
+	outputMutex sync.Mutex
 }
 
 func NewUCIEngine() *UCIEngine {
-	return &UCIEngine{}
+	e := &UCIEngine{searcher: engine.NewSearcher(), Output: os.Stdout}
+	e.searcher.OnIteration = e.sendSearchInfo
+	e.searcher.OnCurrentMove = e.sendCurrentMove
+	e.registerOptions()
+	return e
+}
+
+func (e *UCIEngine) registerOptions() {
+	e.options.Register(&SpinOption{
+		name: "Hash", Default: engine.DefaultHashSize, Min: engine.MinHashSize, Max: engine.MaxHashSize,
+		OnChange: func(v int) { e.searcher.Options.HashSize = v },
+	})
+	e.options.Register(&ButtonOption{
+		name:    "Clear Hash",
+		OnPress: func() { e.searcher.ClearHash() },
+	})
+	e.options.Register(&SpinOption{
+		name: "Threads", Default: engine.DefaultThreads, Min: 1, Max: engine.MaxThreads,
+		OnChange: func(v int) { e.searcher.Options.Threads = v },
+	})
+	e.options.Register(&SpinOption{
+		name: "MultiPV", Default: engine.DefaultMultiPV, Min: 1, Max: engine.MaxMultiPV,
+		OnChange: func(v int) { e.searcher.Options.MultiPV = v },
+	})
+	e.options.Register(&SpinOption{
+		name: "Move Overhead", Default: int(engine.DefaultMoveOverhead.Milliseconds()), Min: 0, Max: int(engine.MaxMoveOverhead.Milliseconds()),
+		OnChange: func(v int) { e.searcher.Options.MoveOverhead = time.Duration(v) * time.Millisecond },
+	})
+	e.options.Register(&CheckOption{
+		name: "OwnBook", Default: false,
+		OnChange: func(v bool) { e.ownBook = v },
+	})
+	e.options.Register(&StringOption{
+		name: "BookFile", Default: "",
+		OnChange: e.loadBook,
+	})
+}
+
+// loadBook replaces the opening book, an empty path or a file that can not be read leaves the engine without one
+func (e *UCIEngine) loadBook(path string) {
+	e.book = nil
+	if path == "" {
+		return
+	}
+
+	b, err := book.Open(path)
+	if err != nil {
+		e.send("info string could not load book %s: %v", path, err)
+		return
+	}
+
+	e.book = b
+	e.send("info string loaded book %s with %d entries", path, b.Len())
 }
 
 func (e *UCIEngine) UCILoop() {
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
 		command := scanner.Text()
-		if err := e.handleCommand(command); err != nil {
-			fmt.Printf("Error: %v\n", err)
+		if err := e.HandleCommand(command); err != nil {
+			e.send("Error: %v", err)
 		}
 	}
+	e.stopSearch()
 }
 
-func (e *UCIEngine) handleCommand(command string) error {
+// HandleCommand runs a single command of the GUI, a go command returns while the search is still running
+func (e *UCIEngine) HandleCommand(command string) error {
 	parts := strings.Fields(command)
 	if len(parts) == 0 {
 		return nil
@@ -34,18 +105,34 @@ func (e *UCIEngine) handleCommand(command string) error {
 
 	switch parts[0] {
 	case "uci":
-		fmt.Println("id name nChess")
-		fmt.Println("id author Noah Endtner")
-		fmt.Println("uciok")
+		e.send("id name nChess")
+		e.send("id author Noah Endtner")
+		for _, declaration := range e.options.Declarations() {
+			e.send(declaration)
+		}
+		e.send("uciok")
 	case "isready":
-		fmt.Println("readyok")
+		e.send("readyok")
+	case "setoption":
+		e.stopSearch()
+		return e.handleSetOption(parts[1:])
 	case "ucinewgame":
-		fmt.Printf("")
+		e.stopSearch()
+		e.searcher.NewGame()
 	case "position":
+		e.stopSearch()
 		return e.handlePosition(parts[1:])
 	case "go":
-		return e.handleGo()
+		e.stopSearch()
+		return e.handleGo(parts[1:])
+	case "stop":
+		e.stopSearch()
+	case "ponderhit":
+		if e.searchDone != nil {
+			e.searcher.PonderHit()
+		}
 	case "quit":
+		e.stopSearch()
 		os.Exit(0)
 	default:
 		return fmt.Errorf("unknown command: %s", parts[0])
@@ -53,3 +140,23 @@ func (e *UCIEngine) handleCommand(command string) error {
 
 	return nil
 }
+
+// send writes a single line to the GUI, the search goroutine and the command loop both write output
+func (e *UCIEngine) send(format string, a ...any) {
+	e.outputMutex.Lock()
+	defer e.outputMutex.Unlock()
+
+	fmt.Fprintf(e.Output, format+"\n", a...)
+}
+
+// stopSearch stops a running search and waits until its bestmove has been sent
+func (e *UCIEngine) stopSearch() {
+	if e.searchDone == nil {
+		return
+	}
+
+	e.searcher.Stop()
+	<-e.searchDone
+
+	e.searchDone = nil
+}
@@ -23,9 +23,6 @@ func FromFen(fenString string) *board.Position {
 	slices.Reverse(figurePositionRows)
 	figurePositions := strings.Join(figurePositionRows, "/")
 
-	p.Bitboards = make([]uint64, 0b1111)
-	p.Pieces = make([]uint8, 64)
-
 	// Setting up pieces
 	boardPosition := 0
 	for i := 0; i < len(figurePositions); i++ {
@@ -122,6 +119,60 @@ func FromFen(fenString string) *board.Position {
 	return &p
 }
 
+// ValidateFEN checks a FEN before it is handed to FromFen, which assumes a well formed one
+func ValidateFEN(fenString string) error {
+	fenFields := strings.Fields(fenString)
+	if len(fenFields) != 6 {
+		return fmt.Errorf("expected 6 fields, got %d", len(fenFields))
+	}
+
+	ranks := strings.Split(fenFields[0], "/")
+	if len(ranks) != 8 {
+		return fmt.Errorf("expected 8 ranks, got %d", len(ranks))
+	}
+
+	kings := map[rune]int{}
+	for i, rank := range ranks {
+		squares := 0
+		for _, c := range rank {
+			switch {
+			case c >= '1' && c <= '8':
+				squares += int(c - '0')
+			case strings.ContainsRune("pnbrqkPNBRQK", c):
+				squares++
+				if c == 'k' || c == 'K' {
+					kings[c]++
+				}
+			default:
+				return fmt.Errorf("unknown piece %q", c)
+			}
+		}
+		if squares != 8 {
+			return fmt.Errorf("rank %d has %d squares", 8-i, squares)
+		}
+	}
+	if kings['K'] != 1 || kings['k'] != 1 {
+		return fmt.Errorf("expected one king per side")
+	}
+
+	if fenFields[1] != "w" && fenFields[1] != "b" {
+		return fmt.Errorf("invalid side to move %s", fenFields[1])
+	}
+	if fenFields[2] != "-" && strings.Trim(fenFields[2], "KQkq") != "" {
+		return fmt.Errorf("invalid castling rights %s", fenFields[2])
+	}
+	if ep := fenFields[3]; ep != "-" && (len(ep) != 2 || ep[0] < 'a' || ep[0] > 'h' || (ep[1] != '3' && ep[1] != '6')) {
+		return fmt.Errorf("invalid en passant square %s", ep)
+	}
+	for _, counter := range fenFields[4:] {
+		if n, err := strconv.Atoi(counter); err != nil || n < 0 {
+			return fmt.Errorf("invalid move counter %s", counter)
+		}
+	}
+
+	return nil
+}
+
 func ToFEN(p *board.Position) string {
 	var fen strings.Builder
 
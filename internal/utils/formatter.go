@@ -56,6 +56,15 @@ func FromMapping(bitboardMapping map[uint64]string) []string {
 }
 
 func ToString(board []string) string {
+	return toString(board, false)
+}
+
+// ToStringFlipped draws the board as seen from black, with h1 in the top left corner
+func ToStringFlipped(board []string) string {
+	return toString(board, true)
+}
+
+func toString(board []string, flipped bool) string {
 	/*
 		claude.ai is responsible for this satanic child of a function, but it does work like a charm
 	*/
@@ -78,24 +87,29 @@ func ToString(board []string) string {
 
 	// Write top border with file letters
 	result.WriteString("  ")
-	for file := 'a'; file <= 'h'; file++ {
-		result.WriteString(fmt.Sprintf(" %c  ", file))
+	for column := 0; column < 8; column++ {
+		result.WriteString(fmt.Sprintf(" %c  ", 'a'+fileAt(column, flipped)))
 	}
 	result.WriteString("\n")
 
 	result.WriteString(" " + topLeft + horizontal + strings.Repeat(horizontal+horizontal+topT+horizontal, 7) + horizontal + horizontal + topRight + "\n")
 
-	for rank := 8; rank >= 1; rank-- {
+	for row := 0; row < 8; row++ {
+		rank := 8 - row
+		if flipped {
+			rank = row + 1
+		}
+
 		result.WriteString(fmt.Sprintf("%d", rank)) // Rank number
 		result.WriteString(vertical)
-		for file := 0; file < 8; file++ {
-			index := (rank-1)*8 + file
+		for column := 0; column < 8; column++ {
+			index := (rank-1)*8 + fileAt(column, flipped)
 			result.WriteString(fmt.Sprintf(" %s ", board[index]))
 			result.WriteString(vertical)
 		}
 		result.WriteString(fmt.Sprintf("%d\n", rank)) // Rank number
 
-		if rank > 1 {
+		if row < 7 {
 			result.WriteString(" " + leftT + horizontal + strings.Repeat(horizontal+horizontal+cross+horizontal, 7) + horizontal + horizontal + rightT + "\n")
 		}
 	}
@@ -104,15 +118,29 @@ func ToString(board []string) string {
 
 	// Write bottom border with file letters
 	result.WriteString("  ")
-	for file := 'a'; file <= 'h'; file++ {
-		result.WriteString(fmt.Sprintf(" %c  ", file))
+	for column := 0; column < 8; column++ {
+		result.WriteString(fmt.Sprintf(" %c  ", 'a'+fileAt(column, flipped)))
 	}
 	result.WriteString("\n")
 
 	return result.String()
 }
 
+// fileAt returns the file shown in the given column, counting from the left
+func fileAt(column int, flipped bool) int {
+	if flipped {
+		return 7 - column
+	}
+	return column
+}
+
 func Display(p *board.Position) {
 	unicodeBoard := FromMapping(BitboardMappingAll(p))
 	fmt.Println(ToString(unicodeBoard))
 }
+
+// DisplayFlipped prints the board from black's side
+func DisplayFlipped(p *board.Position) {
+	unicodeBoard := FromMapping(BitboardMappingAll(p))
+	fmt.Println(ToStringFlipped(unicodeBoard))
+}
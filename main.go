@@ -24,13 +24,14 @@ func main() {
 
 	searchDepth := 32
 	startSearch := time.Now()
-	fmt.Println(board.MoveToString(engine.IterativeDeepeningSearch(p, searchDepth, 15*time.Second)))
+	fmt.Println(board.MoveToString(engine.IterativeDeepeningSearch(p, engine.SearchLimits{Depth: searchDepth, MoveTime: 15 * time.Second})))
 	fmt.Printf("Search(%d) took %s\n", searchDepth, time.Since(startSearch))
 
-	fmt.Println("")
-	fmt.Printf("Precomputation: %s\n", engine.TotalTimePrecompute)
-	fmt.Printf("King Generation: %s\n", engine.TotalTimeKingGeneration)
-	fmt.Printf("Pawn Generation: %s\n", engine.TotalTimePawnGeneration)
-	fmt.Printf("Sliding Generation: %s\n", engine.TotalTimeSlidingGeneration)
-	fmt.Printf("Knight Generation: %s\n", engine.TotalTimeKnightGeneration)
+	// Only measured when built with the 'profile' tag
+	if engine.ProfilingEnabled {
+		fmt.Println("")
+		for phase := range engine.ProfilePhase(engine.ProfilePhases) {
+			fmt.Printf("%s: %s\n", phase, engine.ProfileTime(phase))
+		}
+	}
 }
@@ -0,0 +1,75 @@
+package t
+
+import (
+	"bytes"
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/book"
+	"endtner.dev/nChess/internal/utils"
+	"testing"
+)
+
+func TestPolyglotKey(t *testing.T) {
+	// Keys from the Polyglot specification
+	tests := []struct {
+		moves string
+		key   uint64
+	}{
+		{"", 0x463b96181691fc9c},
+		{"e2e4", 0x823c9b50fd114196},
+		{"e2e4 d7d5", 0x0756b94461c50fb0},
+		{"e2e4 d7d5 e4e5", 0x662fafb965db29d4},
+		{"e2e4 d7d5 e4e5 f7f5", 0x22a48b5a8e47ff78},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652a607ca3f242c1},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00fdd303c946bdd9},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3c8123ea7b067637},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5c3f9b829b279560},
+	}
+
+	for _, test := range tests {
+		p := playMoves(t, utils.FromFen(utils.StartPosition), test.moves)
+		if key := board.GetPolyglotKey(p); key != test.key {
+			t.Errorf("[%s] Expected key %016x, got %016x", test.moves, test.key, key)
+		}
+	}
+}
+
+func TestBookPicksLegalMoves(t *testing.T) {
+	start := utils.FromFen(utils.StartPosition)
+	castlingPosition := utils.FromFen("r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 1")
+
+	e2e4, _ := findLegalMove(start, "e2e4")
+	castling, _ := findLegalMove(castlingPosition, "e1g1")
+
+	entries := []book.Entry{
+		{Key: board.GetPolyglotKey(start), Move: book.EncodeMove(e2e4), Weight: 1},
+		{Key: board.GetPolyglotKey(start), Move: 0b001_001100_000000, Weight: 0}, // Not a legal move
+		{Key: board.GetPolyglotKey(castlingPosition), Move: book.EncodeMove(castling), Weight: 5},
+	}
+
+	var data bytes.Buffer
+	if err := book.Write(&data, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := book.Read(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		p        *board.Position
+		expected string
+	}{
+		{start, "e2e4"},
+		{castlingPosition, "e1g1"},
+	} {
+		m, found := b.PickMove(test.p)
+		if !found || board.MoveToString(m) != test.expected {
+			t.Errorf("Expected book move %s, got %s", test.expected, board.MoveToString(m))
+		}
+	}
+
+	if _, found := b.PickMove(playMoves(t, start, "d2d4")); found {
+		t.Errorf("Found a book move for a position that is not in the book")
+	}
+}
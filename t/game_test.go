@@ -0,0 +1,129 @@
+package t
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/game"
+	"endtner.dev/nChess/internal/pgn"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedPlayer plays its side of a shared script of moves and commands, and declines every draw
+type scriptedPlayer struct {
+	t      *testing.T
+	script *[]string
+
+	// The time players take is added to a shared fake clock, so timed games do not depend on the machine
+	now         *time.Time
+	delay       time.Duration // Time taken for every move or command
+	answerDelay time.Duration // Time taken to decline a draw offer
+}
+
+func (s scriptedPlayer) wait(d time.Duration) {
+	if s.now != nil {
+		*s.now = s.now.Add(d)
+	}
+}
+
+func (s scriptedPlayer) Init() {}
+
+func (s scriptedPlayer) GetPlayerType() byte {
+	return game.Human
+}
+
+func (s scriptedPlayer) AwaitMove(p *board.Position, legalMoveTable *map[string]board.Move) board.Move {
+	m, _ := s.AwaitMoveOrCommand(p, legalMoveTable)
+	return m
+}
+
+func (s scriptedPlayer) AwaitMoveOrCommand(p *board.Position, legalMoveTable *map[string]board.Move) (board.Move, game.Command) {
+	if len(*s.script) == 0 {
+		s.t.Fatal("The script ended before the game")
+	}
+	s.wait(s.delay)
+	input := (*s.script)[0]
+	*s.script = (*s.script)[1:]
+
+	if command, found := game.ParseCommand(input); found {
+		return board.NullMove, command
+	}
+	m, err := pgn.ParseMoveInput(p, input)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return m, game.Command{}
+}
+
+func (s scriptedPlayer) AcceptsDraw(p *board.Position) bool {
+	s.wait(s.answerDelay)
+	return false
+}
+
+func TestGameCommands(t *testing.T) {
+	script := []string{"e4", "e5", "undo", "d4", "draw", "fen", "resign"}
+	player := scriptedPlayer{t: t, script: &script}
+
+	g := game.NewGame(player, player)
+	g.RunGameLoop()
+
+	if g.Result() != board.Win(true, board.Resignation) {
+		t.Errorf("Expected white to win by resignation, got %s", g.Result())
+	}
+
+	text := g.PGN()
+	if !strings.Contains(text, `[Result "1-0"]`) || !strings.Contains(text, `[Termination "normal"]`) || !strings.HasSuffix(strings.TrimSpace(text), "1. d4 1-0") {
+		t.Errorf("Unexpected game after undo and resignation:\n%s", text)
+	}
+}
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected game.TimeControl
+	}{
+		{"300", game.TimeControl{Base: 300 * time.Second}},
+		{"180+2", game.TimeControl{Base: 180 * time.Second, Increment: 2 * time.Second}},
+		{"40/5400+30", game.TimeControl{Base: 5400 * time.Second, Increment: 30 * time.Second, MovesPerPeriod: 40}},
+		{"0.5+0.1", game.TimeControl{Base: 500 * time.Millisecond, Increment: 100 * time.Millisecond}},
+	}
+
+	for _, test := range tests {
+		tc, err := game.ParseTimeControl(test.text, game.Fischer)
+		if err != nil || tc != test.expected {
+			t.Errorf("[%s] Expected %+v, got %+v (%v)", test.text, test.expected, tc, err)
+		}
+		if tag, _ := tc.PGNTag(); err == nil && tag != test.text {
+			t.Errorf("[%s] Expected the same PGN tag, got %s", test.text, tag)
+		}
+	}
+
+	for _, text := range []string{"", "abc", "0+2", "x/300", "300+y"} {
+		if _, err := game.ParseTimeControl(text, game.Fischer); err == nil {
+			t.Errorf("[%s] Expected an error", text)
+		}
+	}
+}
+
+func TestLossOnTime(t *testing.T) {
+	// White takes a long time to decline the draw offer, which must not be charged to black
+	script := []string{"e4", "draw", "e5", "Nf3"}
+	now := time.Now()
+	white := scriptedPlayer{t: t, script: &script, now: &now, delay: 30 * time.Millisecond, answerDelay: time.Minute}
+	black := scriptedPlayer{t: t, script: &script, now: &now}
+
+	g := game.NewGame(white, black)
+	g.Now = func() time.Time { return now }
+	g.SetTimeControl(game.TimeControl{Base: 50 * time.Millisecond})
+	g.RunGameLoop()
+
+	// White had time for the first move, but not for the second one
+	if g.Result() != board.Win(false, board.Timeout) {
+		t.Errorf("Expected black to win on time, got %s", g.Result())
+	}
+
+	text := g.PGN()
+	if !strings.Contains(text, `[Result "0-1"]`) || !strings.Contains(text, `[Termination "time forfeit"]`) || !strings.Contains(text, "1. e4") || strings.Contains(text, "Nf3") {
+		t.Errorf("Unexpected game after a loss on time:\n%s", text)
+	}
+}
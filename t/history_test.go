@@ -0,0 +1,66 @@
+package t
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/engine"
+	"endtner.dev/nChess/internal/utils"
+	"testing"
+)
+
+func TestHistoryGravity(t *testing.T) {
+	// The same position once without and once with a move before it, so the butterfly and continuation scores can be told apart
+	withoutPrevious := utils.FromFen("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	withPrevious := utils.FromFen(utils.StartPosition)
+	e2e4, _ := findLegalMove(withPrevious, "e2e4")
+	withPrevious.MakeMove(e2e4)
+
+	g8f6, _ := findLegalMove(withPrevious, "g8f6")
+	b8c6, _ := findLegalMove(withPrevious, "b8c6")
+
+	history := new(engine.MoveHistory)
+
+	// checkBounds returns the butterfly and continuation score of the move, failing the test if one is out of bounds
+	checkBounds := func(m board.Move, round int) (int, int) {
+		butterfly := history.Score(withoutPrevious, m)
+		continuation := history.Score(withPrevious, m) - butterfly
+
+		if butterfly < -engine.MaxHistory || butterfly > engine.MaxHistory {
+			t.Fatalf("[%s, round %d] Butterfly score %d out of bounds", board.MoveToString(m), round, butterfly)
+		}
+		if continuation < -engine.MaxHistory || continuation > engine.MaxHistory {
+			t.Fatalf("[%s, round %d] Continuation score %d out of bounds", board.MoveToString(m), round, continuation)
+		}
+		return butterfly, continuation
+	}
+
+	// Deep cutoffs get the largest bonus, g8f6 keeps refuting e2e4 while b8c6 gets searched first every time
+	for round := range 1000 {
+		history.Update(withPrevious, g8f6, []board.Move{b8c6, g8f6}, engine.MaxDepth)
+		checkBounds(g8f6, round)
+		checkBounds(b8c6, round)
+	}
+
+	if butterfly, continuation := checkBounds(g8f6, 1000); butterfly < engine.MaxHistory*9/10 || continuation < engine.MaxHistory*9/10 {
+		t.Errorf("Expected the bonuses to converge towards %d, got %d and %d", engine.MaxHistory, butterfly, continuation)
+	}
+	if butterfly, continuation := checkBounds(b8c6, 1000); butterfly > -engine.MaxHistory*9/10 || continuation > -engine.MaxHistory*9/10 {
+		t.Errorf("Expected the maluses to converge towards %d, got %d and %d", -engine.MaxHistory, butterfly, continuation)
+	}
+	if counterMove := history.CounterMove(withPrevious); counterMove != g8f6 {
+		t.Errorf("Expected counter move g8f6, got %s", board.MoveToString(counterMove))
+	}
+
+	// The roles switch, the scores have to come all the way back without leaving the bounds
+	for round := range 1000 {
+		history.Update(withPrevious, b8c6, []board.Move{g8f6, b8c6}, engine.MaxDepth)
+		checkBounds(g8f6, round)
+		checkBounds(b8c6, round)
+	}
+
+	if butterfly, continuation := checkBounds(b8c6, 1000); butterfly < engine.MaxHistory*9/10 || continuation < engine.MaxHistory*9/10 {
+		t.Errorf("Expected the bonuses to converge towards %d, got %d and %d", engine.MaxHistory, butterfly, continuation)
+	}
+	if counterMove := history.CounterMove(withPrevious); counterMove != b8c6 {
+		t.Errorf("Expected counter move b8c6, got %s", board.MoveToString(counterMove))
+	}
+}
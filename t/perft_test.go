@@ -1,18 +1,16 @@
 package t
 
 import (
+	"endtner.dev/nChess/internal/board"
 	"endtner.dev/nChess/internal/engine"
 	"endtner.dev/nChess/internal/utils"
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 )
 
-/*
-	These tests can NOT run in parallel without interfering with the move generator.
-*/
-
 func doPerftTest(positionName string, positionFen string, expectedPerftResults []int64) bool {
 	testingResult := true
 
@@ -41,6 +39,8 @@ func doPerftTest(positionName string, positionFen string, expectedPerftResults [
 */
 
 func TestPerftInitialPosition(t *testing.T) {
+	t.Parallel()
+
 	positionName := "Initial Position"
 	positionFen := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
 	expectedPerftResults := []int64{1, 20, 400, 8902, 197281, 4865609, 119060324}
@@ -51,6 +51,8 @@ func TestPerftInitialPosition(t *testing.T) {
 }
 
 func TestPerftPosition2(t *testing.T) {
+	t.Parallel()
+
 	positionName := "Position 2"
 	positionFen := "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"
 	expectedPerftResults := []int64{1, 48, 2039, 97862, 4085603, 193690690}
@@ -61,6 +63,8 @@ func TestPerftPosition2(t *testing.T) {
 }
 
 func TestPerftPosition3(t *testing.T) {
+	t.Parallel()
+
 	positionName := "Position 3"
 	positionFen := "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1"
 	expectedPerftResults := []int64{1, 14, 191, 2812, 43238, 674624, 11030083}
@@ -71,6 +75,8 @@ func TestPerftPosition3(t *testing.T) {
 }
 
 func TestPerftPosition4(t *testing.T) {
+	t.Parallel()
+
 	positionName := "Position 4"
 	positionFen := "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1"
 	expectedPerftResults := []int64{1, 6, 264, 9467, 422333, 15833292}
@@ -81,6 +87,8 @@ func TestPerftPosition4(t *testing.T) {
 }
 
 func TestPerftPosition5(t *testing.T) {
+	t.Parallel()
+
 	positionName := "Position 5"
 	positionFen := "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8"
 	expectedPerftResults := []int64{1, 44, 1486, 62379, 2103487, 89941194}
@@ -91,6 +99,8 @@ func TestPerftPosition5(t *testing.T) {
 }
 
 func TestPerftPosition6(t *testing.T) {
+	t.Parallel()
+
 	positionName := "Position 6"
 	positionFen := "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10"
 	expectedPerftResults := []int64{1, 46, 2079, 89890, 3894594, 164075551} //, 6923051137}
@@ -99,3 +109,103 @@ func TestPerftPosition6(t *testing.T) {
 		t.Errorf("[%s] Testing Failed", positionName)
 	}
 }
+
+func TestGenerateMovesDoesNotAllocate(t *testing.T) {
+	p := utils.FromFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+
+	var moves engine.MoveBuffer
+	allocations := testing.AllocsPerRun(100, func() {
+		engine.GenerateMoves(p, engine.AllMoves, &moves)
+	})
+
+	if allocations != 0 {
+		t.Errorf("GenerateMoves allocated %.0f times per call", allocations)
+	}
+}
+
+func TestUnmakeMoveRestoresPosition(t *testing.T) {
+	p := utils.FromFen("r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1")
+
+	var walk func(depth int)
+	walk = func(depth int) {
+		if depth == 0 {
+			return
+		}
+
+		for _, m := range engine.LegalMoves(p) {
+			if m.Unpack().Pack() != m {
+				t.Fatalf("Move %s changed by unpacking and packing it again", board.MoveToString(m))
+			}
+
+			before := *p
+
+			p.MakeMove(m)
+			walk(depth - 1)
+			p.UnmakeMove()
+
+			if p.Bitboards != before.Bitboards || p.Pieces != before.Pieces || p.Zobrist != before.Zobrist || utils.ToFEN(p) != utils.ToFEN(&before) {
+				t.Fatalf("UnmakeMove(%s) did not restore %s, got %s", board.MoveToString(m), utils.ToFEN(&before), utils.ToFEN(p))
+			}
+		}
+	}
+	walk(3)
+}
+
+func TestMovePickerReturnsEveryLegalMoveOnce(t *testing.T) {
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	}
+
+	// Positions up to two plies deep, their moves serve as hash and killer moves in all of the other positions
+	var positions []*board.Position
+	var foreignMoves []board.Move
+
+	var walk func(p *board.Position, depth int)
+	walk = func(p *board.Position, depth int) {
+		positions = append(positions, p)
+		for _, m := range engine.LegalMoves(p) {
+			if !slices.Contains(foreignMoves, m) {
+				foreignMoves = append(foreignMoves, m)
+			}
+			if depth > 0 {
+				walk(p.MakeMoveCopy(m), depth-1)
+			}
+		}
+	}
+	for _, fen := range fens {
+		walk(utils.FromFen(fen), 2)
+	}
+
+	history := new(engine.MoveHistory)
+	var picker engine.MovePicker
+
+	for _, p := range positions {
+		legalMoves := engine.LegalMoves(p)
+		slices.Sort(legalMoves)
+
+		for i, foreign := range foreignMoves {
+			if engine.IsLegal(p, foreign) != slices.Contains(legalMoves, foreign) {
+				t.Fatalf("IsLegal(%s) is wrong in %s", board.MoveToString(foreign), utils.ToFEN(p))
+			}
+
+			if i%16 != 0 {
+				continue
+			}
+
+			killers := [2]board.Move{foreignMoves[(i+1)%len(foreignMoves)], foreignMoves[(i+2)%len(foreignMoves)]}
+			picker.Init(p, foreign, killers, history)
+
+			var picked []board.Move
+			for m := picker.Next(); m != board.NullMove; m = picker.Next() {
+				picked = append(picked, m)
+			}
+
+			slices.Sort(picked)
+			if !slices.Equal(picked, legalMoves) {
+				t.Fatalf("Move picker returned %d moves instead of the %d legal moves in %s", len(picked), len(legalMoves), utils.ToFEN(p))
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package t
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/pgn"
+	"endtner.dev/nChess/internal/utils"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSAN(t *testing.T) {
+	tests := []struct {
+		fen      string
+		san      string
+		expected string
+	}{
+		{utils.StartPosition, "Nf3", "g1f3"},
+		{utils.StartPosition, "e4", "e2e4"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "O-O-O", "e8c8"},
+		{"4k3/8/8/8/8/8/1N3N2/4K3 w - - 0 1", "Nbd3", "b2d3"},
+		{"4k3/8/8/8/8/5N2/8/4KN2 w - - 0 1", "N3d2+", "f3d2"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a8=Q+", "a7a8q"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a8N", "a7a8n"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "exd6", "e5d6"},
+	}
+
+	for _, test := range tests {
+		m, err := pgn.ParseSAN(utils.FromFen(test.fen), test.san)
+		if err != nil || board.MoveToString(m) != test.expected {
+			t.Errorf("[%s] Expected %s, got %s (%v)", test.san, test.expected, board.MoveToString(m), err)
+		}
+	}
+
+	for _, san := range []string{"Nd2", "Ke3", "O-O"} {
+		if _, err := pgn.ParseSAN(utils.FromFen("4k3/8/8/8/8/5N2/8/4KN2 w - - 0 1"), san); err == nil {
+			t.Errorf("[%s] Expected an error for an ambiguous or impossible move", san)
+		}
+	}
+}
+
+func TestReadPGN(t *testing.T) {
+	text := `[Event "First"]
+[White "Player \"One\""]
+[Result "1-0"]
+
+{Opening comment} 1. e4 {King pawn} e5 2. Nf3 (2. f4 exf4 (2... d5)) Nc6 $1 ; line comment
+3.Bb5 a6!? 1-0
+
+[Event "Broken"]
+
+1. e4 e5 2. Ke3 *
+
+[Event "Missing fields"]
+[FEN "8/8/8/8/8/8/8/8"]
+
+1. e4 *
+
+[Event "Unknown pieces"]
+[FEN "zz/8 w - - 0 1"]
+
+1. e4 *
+
+[Event "Third"]
+[FEN "4k3/P7/8/3pP3/8/8/8/R3K3 w Q d6 0 1"]
+
+1. exd6 e.p. Kd7 2. a8=Q+ Kxd6 3. O-O-O+ 1/2-1/2`
+
+	reader := pgn.NewReader(strings.NewReader(text))
+
+	first, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Tags["Event"] != "First" || first.Tags["White"] != `Player "One"` || first.Result != pgn.ResultWhiteWins || first.Comment != "Opening comment" {
+		t.Errorf("Wrong tags, comment or result in the first game: %v %q %s", first.Tags, first.Comment, first.Result)
+	}
+
+	var sans []string
+	for _, node := range first.Moves {
+		sans = append(sans, node.SAN)
+	}
+	if !slices.Equal(sans, []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6"}) {
+		t.Errorf("Wrong moves in the first game: %v", sans)
+	}
+
+	nf3 := first.Moves[2]
+	if first.Moves[0].Comment != "King pawn" || !slices.Equal(first.Moves[3].NAGs, []int{1}) || !slices.Equal(first.Moves[5].NAGs, []int{5}) {
+		t.Errorf("Wrong comments or NAGs in the first game")
+	}
+	if len(nf3.Variations) != 1 || len(nf3.Variations[0]) != 2 || len(nf3.Variations[0][1].Variations) != 1 ||
+		board.MoveToString(nf3.Variations[0][1].Variations[0][0].Move) != "d7d5" {
+		t.Errorf("Wrong variations in the first game")
+	}
+
+	// The broken games are skipped, the reader continues after them
+	for i := 0; i < 3; i++ {
+		var invalidGame *pgn.InvalidGameError
+		if _, err := reader.Next(); !errors.As(err, &invalidGame) {
+			t.Errorf("Expected an invalid game, got %v", err)
+		}
+	}
+
+	third, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions := third.Positions()
+	if len(positions) != 6 || third.Result != pgn.ResultDraw {
+		t.Fatalf("Wrong number of positions or result in the third game: %d %s", len(positions), third.Result)
+	}
+	if fen := utils.ToFEN(positions[5]); fen != "Q7/8/3k4/8/8/8/8/2KR4 b - - 1 3" {
+		t.Errorf("Wrong final position in the third game: %s", fen)
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last game, got %v", err)
+	}
+}
+
+func TestFormatSAN(t *testing.T) {
+	tests := []struct {
+		fen      string
+		move     string
+		expected string
+	}{
+		{utils.StartPosition, "g1f3", "Nf3"},
+		{"4k3/8/8/8/8/8/1N3N2/4K3 w - - 0 1", "b2d3", "Nbd3"},
+		{"4k3/8/8/8/8/5N2/8/4KN2 w - - 0 1", "f3d2", "N3d2"},
+		{"k7/8/4N3/8/8/8/2N1N3/7K w - - 0 1", "e2d4", "Ne2d4"},
+		{"4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", "e4d5", "exd5"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", "exd6"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8q", "a8=Q+"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1", "d1d8", "Rd8#"},
+	}
+
+	for _, test := range tests {
+		p := utils.FromFen(test.fen)
+		m, _ := findLegalMove(p, test.move)
+
+		if san := pgn.FormatSAN(p, m); san != test.expected {
+			t.Errorf("[%s] Expected %s, got %s", test.move, test.expected, san)
+		}
+		if fen := utils.ToFEN(p); fen != test.fen {
+			t.Errorf("[%s] FormatSAN changed the position to %s", test.move, fen)
+		}
+	}
+}
+
+func TestParseMoveInput(t *testing.T) {
+	tests := []struct {
+		fen      string
+		input    string
+		expected string // Empty if the input should be rejected
+	}{
+		{utils.StartPosition, "Nf3", "g1f3"},
+		{utils.StartPosition, "nf3", "g1f3"},
+		{utils.StartPosition, "G1F3", "g1f3"},
+		{utils.StartPosition, "e2-e4", "e2e4"},
+		{utils.StartPosition, "e2e5", ""},
+		{utils.StartPosition, "Nd4", ""},
+		{"4k3/8/8/8/8/8/1N3N2/4K3 w - - 0 1", "Nd3", ""},
+		{"4k3/8/8/8/8/8/1N3N2/4K3 w - - 0 1", "nbd3", "b2d3"},
+		{"4k3/8/8/2p5/1P6/8/8/4KB2 w - - 0 1", "bxc5", "b4c5"},
+		{"4k3/8/8/8/8/2p5/1P6/4KB2 w - - 0 1", "bd3", "f1d3"},
+		{"4k3/8/8/8/8/2p5/1P1B4/4K3 w - - 0 1", "bxc3", ""},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a8=q", "a7a8q"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8N", "a7a8n"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "o-o-o", "e8c8"},
+	}
+
+	for _, test := range tests {
+		p := utils.FromFen(test.fen)
+		m, err := pgn.ParseMoveInput(p, test.input)
+
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("[%s] Expected an error, got %s", test.input, board.MoveToString(m))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] %v", test.input, err)
+		} else if moveString := board.MoveToString(m); moveString != test.expected {
+			t.Errorf("[%s] Expected %s, got %s", test.input, test.expected, moveString)
+		}
+	}
+}
+
+func TestWritePGN(t *testing.T) {
+	text := `[Event "Export"]
+[Annotator "Test"]
+[Result "0-1"]
+
+1. f3 {Weak} e5 (1... e6 2. g4) 2. g4 Qh4# 0-1`
+
+	game, err := pgn.NewReader(strings.NewReader(text)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	game.Moves[3].Eval = "#-1"
+
+	expected := `[Event "Export"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "0-1"]
+[Annotator "Test"]
+
+1. f3 {Weak} 1... e5 (1... e6 2. g4) 2. g4 Qh4# {[%eval #-1]} 0-1
+
+`
+	if exported := game.String(); exported != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, exported)
+	}
+
+	// The exported game reads back to the same moves
+	reread, err := pgn.NewReader(strings.NewReader(game.String())).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reread.Moves) != len(game.Moves) || reread.Moves[3].Move != game.Moves[3].Move || reread.Result != game.Result {
+		t.Errorf("Exported game does not read back to the same game")
+	}
+}
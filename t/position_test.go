@@ -0,0 +1,67 @@
+package t
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/engine"
+	"endtner.dev/nChess/internal/utils"
+	"strings"
+	"testing"
+)
+
+func playMoves(t *testing.T, p *board.Position, moves string) *board.Position {
+	for _, moveString := range strings.Fields(moves) {
+		m, found := findLegalMove(p, moveString)
+		if !found {
+			t.Fatalf("Move %s is not legal in %s", moveString, utils.ToFEN(p))
+		}
+		p = p.MakeMoveCopy(m)
+	}
+
+	// Generating the moves updates the terminal state
+	engine.LegalMoves(p)
+	return p
+}
+
+func TestRepetition(t *testing.T) {
+	knightShuffle := "g1f3 g8f6 f3g1 f6g8 "
+
+	p := playMoves(t, utils.FromFen(utils.StartPosition), knightShuffle)
+	if !p.IsRepetition() || p.IsTerminal() {
+		t.Errorf("Twofold repetition: IsRepetition=%t, IsTerminal=%t", p.IsRepetition(), p.IsTerminal())
+	}
+
+	p = playMoves(t, utils.FromFen(utils.StartPosition), strings.Repeat(knightShuffle, 2))
+	if p.Result != board.DrawBy(board.ThreefoldRepetition) {
+		t.Errorf("Threefold repetition: got %q", p.Result)
+	}
+
+	p = playMoves(t, utils.FromFen(utils.StartPosition), strings.Repeat(knightShuffle, 4))
+	if p.Result != board.DrawBy(board.FivefoldRepetition) {
+		t.Errorf("Fivefold repetition: got %q", p.Result)
+	}
+
+	// A pawn move in between makes the earlier positions unreachable
+	p = playMoves(t, utils.FromFen(utils.StartPosition), knightShuffle+"e2e3 e7e6 "+knightShuffle)
+	if !p.IsRepetition() || p.IsThreefoldRepetition() {
+		t.Errorf("Repetition after pawn move: IsRepetition=%t, IsThreefoldRepetition=%t", p.IsRepetition(), p.IsThreefoldRepetition())
+	}
+}
+
+func TestSeventyFiveMoveRule(t *testing.T) {
+	p := playMoves(t, utils.FromFen("8/8/8/4k3/8/8/8/R3K3 w - - 148 100"), "a1a2")
+	if p.Result != board.DrawBy(board.FiftyMoveRule) {
+		t.Errorf("Fifty-move rule: got %q", p.Result)
+	}
+
+	p = playMoves(t, p, "e5e6")
+	if p.Result != board.DrawBy(board.SeventyFiveMoveRule) {
+		t.Errorf("Seventy-five-move rule: got %q", p.Result)
+	}
+}
+
+func TestCheckmateResult(t *testing.T) {
+	p := playMoves(t, utils.FromFen(utils.StartPosition), "f2f3 e7e5 g2g4 d8h4")
+	if p.Result != board.Win(false, board.Checkmate) || p.Result.String() != "Black wins by checkmate" {
+		t.Errorf("Fool's mate: got %q", p.Result)
+	}
+}
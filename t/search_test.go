@@ -0,0 +1,243 @@
+package t
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/engine"
+	"endtner.dev/nChess/internal/utils"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+var mateTests = []struct {
+	name     string
+	fen      string
+	depth    int
+	bestMove string
+	mateIn   int
+}{
+	{"Back rank mate", "6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1", 2, "d1d8", 1},
+	{"Rook mate in two", "7k/8/5K2/8/8/8/8/1R6 w - - 0 1", 4, "f6g6", 2},
+	{"Mate on the fiftieth move", "6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 99 80", 4, "d1d8", 1},
+	{"Getting mated", "7k/8/6K1/8/8/8/8/R7 b - - 0 1", 3, "h8g8", -1},
+}
+
+func TestSearchFindsMate(t *testing.T) {
+	tests := mateTests
+
+	// The selective search must not prune away any of the mates
+	featureSets := map[string]engine.SearchFeatures{
+		"all features": engine.AllSearchFeatures(),
+		"no features":  {},
+	}
+
+	for featureName, features := range featureSets {
+		for _, test := range tests {
+			var lastInfo engine.SearchInfo
+
+			searcher := engine.NewSearcher()
+			searcher.Options.Features = features
+			searcher.OnIteration = func(info engine.SearchInfo) { lastInfo = info }
+
+			bestMove := searcher.Search(utils.FromFen(test.fen), engine.SearchLimits{Depth: test.depth})
+
+			if board.MoveToString(bestMove) != test.bestMove {
+				t.Errorf("[%s, %s] Expected best move %s, got %s", test.name, featureName, test.bestMove, board.MoveToString(bestMove))
+			}
+			if !engine.IsMateScore(lastInfo.Score) || engine.MateInMoves(lastInfo.Score) != test.mateIn {
+				t.Errorf("[%s, %s] Expected mate in %d, got score %d", test.name, featureName, test.mateIn, lastInfo.Score)
+			}
+		}
+	}
+}
+
+func TestSearchKeepsPrincipalVariation(t *testing.T) {
+	positions := []string{
+		utils.StartPosition,
+		"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	}
+
+	for _, fen := range positions {
+		searcher := engine.NewSearcher()
+
+		// Every iteration is checked, a transposition table hit at a PV node used to cut the line after one move
+		searcher.OnIteration = func(info engine.SearchInfo) {
+			if info.Depth >= 4 && len(info.PV) < 2 {
+				t.Errorf("[%s] Principal variation at depth %d has only %d moves", fen, info.Depth, len(info.PV))
+			}
+		}
+		// The second search finds the whole tree of the first one in the transposition table
+		searcher.Search(utils.FromFen(fen), engine.SearchLimits{Depth: 6})
+		searcher.Search(utils.FromFen(fen), engine.SearchLimits{Depth: 8})
+
+		if pv := searcher.PrincipalVariation(); len(pv) < 2 {
+			t.Errorf("[%s] Expected a principal variation with a move to ponder on, got %d moves", fen, len(pv))
+		}
+	}
+}
+
+// Helper threads share the transposition table without locks, run with -race to check that they do not race otherwise
+func TestLazySMPFindsMate(t *testing.T) {
+	for _, test := range mateTests {
+		var lastInfo engine.SearchInfo
+
+		searcher := engine.NewSearcher()
+		searcher.Options.Threads = 4
+		searcher.OnIteration = func(info engine.SearchInfo) { lastInfo = info }
+
+		p := utils.FromFen(test.fen)
+		bestMove := searcher.Search(p, engine.SearchLimits{Depth: test.depth})
+
+		if !slices.Contains(engine.LegalMoves(p), bestMove) {
+			t.Errorf("[%s] Best move %s is not legal", test.name, board.MoveToString(bestMove))
+		}
+		if board.MoveToString(bestMove) != test.bestMove {
+			t.Errorf("[%s] Expected best move %s, got %s", test.name, test.bestMove, board.MoveToString(bestMove))
+		}
+		if !engine.IsMateScore(lastInfo.Score) || engine.MateInMoves(lastInfo.Score) != test.mateIn {
+			t.Errorf("[%s] Expected mate in %d, got score %d", test.name, test.mateIn, lastInfo.Score)
+		}
+	}
+}
+
+// awaitMove waits for the result of a background search, failing the test if it does not come in time
+func awaitMove(t *testing.T, result <-chan board.Move, timeout time.Duration) board.Move {
+	t.Helper()
+
+	select {
+	case m := <-result:
+		return m
+	case <-time.After(timeout):
+		t.Fatalf("The search did not return within %v", timeout)
+		return board.NullMove
+	}
+}
+
+func TestStopEndsInfiniteSearch(t *testing.T) {
+	p := utils.FromFen(utils.StartPosition)
+	searcher := engine.NewSearcher()
+
+	result := searcher.Start(p, engine.SearchLimits{Infinite: true})
+	time.Sleep(100 * time.Millisecond)
+	searcher.Stop()
+
+	m := awaitMove(t, result, 2*time.Second)
+	if !slices.Contains(engine.LegalMoves(p), m) {
+		t.Errorf("Expected a legal move after stop, got %s", board.MoveToString(m))
+	}
+
+	// An infinite search may report its move once it was stopped
+	searcher.WaitForRelease()
+}
+
+func TestPonderSearchWaitsForPonderHit(t *testing.T) {
+	p := utils.FromFen(utils.StartPosition)
+	searcher := engine.NewSearcher()
+
+	// The move time only starts to count with the ponderhit
+	result := searcher.Start(p, engine.SearchLimits{Ponder: true, MoveTime: 50 * time.Millisecond})
+	select {
+	case m := <-result:
+		t.Fatalf("The pondering search returned %s before the ponderhit", board.MoveToString(m))
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	searcher.PonderHit()
+	if m := awaitMove(t, result, 5*time.Second); !slices.Contains(engine.LegalMoves(p), m) {
+		t.Errorf("Expected a legal move after the ponderhit, got %s", board.MoveToString(m))
+	}
+	searcher.WaitForRelease()
+
+	// A depth limit may end the search early, but the move is held back until the ponderhit
+	result = searcher.Start(p, engine.SearchLimits{Ponder: true, Depth: 2})
+	awaitMove(t, result, 5*time.Second)
+
+	released := make(chan struct{})
+	go func() {
+		searcher.WaitForRelease()
+		close(released)
+	}()
+
+	select {
+	case <-released:
+		t.Fatal("The pondering search was released before the ponderhit")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	searcher.PonderHit()
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		t.Error("The pondering search was not released by the ponderhit")
+	}
+}
+
+func TestQuiescenceSeesRecaptures(t *testing.T) {
+	tests := []struct {
+		name      string
+		fen       string
+		forbidden string // Looks good at depth 1, but the quiescence search sees the recapture
+		fromMust  string // Square the best move has to start on, empty if any other move will do
+	}{
+		{"Defended pawn", "4k3/8/4p3/3p4/8/8/8/3QK3 w - - 0 1", "d1d5", ""},
+		{"Hanging knight", "4k3/8/8/3p4/4N3/8/8/4K3 w - - 0 1", "", "e4"},
+	}
+
+	for _, test := range tests {
+		p := utils.FromFen(test.fen)
+		bestMove := board.MoveToString(engine.NewSearcher().Search(p, engine.SearchLimits{Depth: 1}))
+
+		if bestMove == test.forbidden || (test.fromMust != "" && bestMove[:2] != test.fromMust) {
+			t.Errorf("[%s] Best move %s leaves material en prise", test.name, bestMove)
+		}
+	}
+}
+
+func TestAspirationWindowsKeepResult(t *testing.T) {
+	// Quiet positions are left out, pruning against a narrow window may settle on another move of about the same score
+	positions := []string{
+		"4k3/8/8/3p4/4N3/8/8/4K3 w - - 0 1",
+		"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	}
+	for _, test := range mateTests {
+		positions = append(positions, test.fen)
+	}
+
+	featureSets := map[string]engine.SearchFeatures{
+		"all features": engine.AllSearchFeatures(),
+		"no features":  {},
+	}
+
+	// A narrow root window only speeds up the search, the re-searches have to end in the same result on every iteration
+	search := func(fen string, features engine.SearchFeatures, aspiration bool) []string {
+		var iterations []string
+
+		searcher := engine.NewSearcher()
+		searcher.Options.Features = features
+		searcher.Options.Features.AspirationWindows = aspiration
+		searcher.OnIteration = func(info engine.SearchInfo) {
+			bestMove := board.NullMove
+			if len(info.PV) > 0 {
+				bestMove = info.PV[0]
+			}
+			iterations = append(iterations, fmt.Sprintf("depth %d: %s (%d)", info.Depth, board.MoveToString(bestMove), info.Score))
+		}
+
+		searcher.Search(utils.FromFen(fen), engine.SearchLimits{Depth: 6})
+		return iterations
+	}
+
+	for featureName, features := range featureSets {
+		for _, fen := range positions {
+			with := search(fen, features, true)
+			without := search(fen, features, false)
+
+			if !slices.Equal(with, without) {
+				t.Errorf("[%s, %s] Aspiration windows changed the iterations from %v to %v", fen, featureName, without, with)
+			}
+		}
+	}
+}
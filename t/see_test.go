@@ -0,0 +1,49 @@
+package t
+
+import (
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/engine"
+	"endtner.dev/nChess/internal/utils"
+	"testing"
+)
+
+func findLegalMove(p *board.Position, moveString string) (board.Move, bool) {
+	for _, m := range engine.LegalMoves(p) {
+		if board.MoveToString(m) == moveString {
+			return m, true
+		}
+	}
+	return board.NullMove, false
+}
+
+func TestSEE(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		move   string
+		result int
+	}{
+		{"Undefended pawn", "1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "e1e5", engine.PawnValue},
+		{"X-ray exchange", "1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1", "d3e5", engine.PawnValue - engine.KnightValue},
+		{"Rook takes pawn defended by pawn", "4k3/8/4p3/3p4/8/8/8/3RK3 w - - 0 1", "d1d5", engine.PawnValue - engine.RookValue},
+		{"Pawn takes defended knight", "4k3/2p5/3n4/4P3/8/8/8/4K3 w - - 0 1", "e5d6", engine.KnightValue - engine.PawnValue},
+		{"Even knight trade", "4k3/2p5/3n4/8/4N3/8/8/4K3 w - - 0 1", "e4d6", 0},
+		{"En passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", engine.PawnValue},
+	}
+
+	for _, test := range tests {
+		p := utils.FromFen(test.fen)
+
+		m, found := findLegalMove(p, test.move)
+		if !found {
+			t.Fatalf("[%s] Move %s is not legal", test.name, test.move)
+		}
+
+		if !engine.SEE(p, m, test.result) {
+			t.Errorf("[%s] SEE(%s) >= %d expected to be true", test.name, test.move, test.result)
+		}
+		if engine.SEE(p, m, test.result+1) {
+			t.Errorf("[%s] SEE(%s) >= %d expected to be false", test.name, test.move, test.result+1)
+		}
+	}
+}
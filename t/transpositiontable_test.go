@@ -0,0 +1,66 @@
+package t
+
+import (
+	"endtner.dev/nChess/internal/engine"
+	"endtner.dev/nChess/internal/utils"
+	"testing"
+)
+
+func TestTranspositionTableGenerations(t *testing.T) {
+	// A table without memory has a single slot, so every key competes for it
+	tt := engine.NewTranspositionTable(0)
+
+	tt.NewSearch()
+	tt.Store(1, 10, 0, 50, -100, 100, 0)
+
+	// The entry is kept for later searches
+	for range 255 {
+		tt.NewSearch()
+	}
+	if entry, found := tt.Probe(1); !found || entry.Depth != 10 {
+		t.Fatalf("Expected the entry to survive later searches, got %+v (%t)", entry, found)
+	}
+
+	// Exactly 256 searches later the deep entry is still stale, so a shallow entry of the running search replaces it
+	tt.NewSearch()
+	tt.Store(2, 1, 0, 50, -100, 100, 0)
+	if _, found := tt.Probe(2); !found {
+		t.Error("An entry of an old search was not replaced")
+	}
+
+	// Entries of the running search are only replaced by deeper ones
+	tt.Store(3, 0, 0, 50, -100, 100, 0)
+	if _, found := tt.Probe(2); !found {
+		t.Error("An entry of the running search was replaced by a shallower one")
+	}
+
+	tt.Clear()
+	if _, found := tt.Probe(2); found {
+		t.Error("Clear kept an entry")
+	}
+}
+
+func TestNewGameClearsTranspositionTable(t *testing.T) {
+	p := utils.FromFen("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	limits := engine.SearchLimits{Depth: 6}
+
+	var nodes int64
+	searcher := engine.NewSearcher()
+	searcher.OnIteration = func(info engine.SearchInfo) { nodes = info.Nodes }
+
+	searcher.Search(p, limits)
+	firstNodes := nodes
+
+	// The second search finds the first one in the table
+	searcher.Search(p, limits)
+	if nodes >= firstNodes {
+		t.Errorf("Expected fewer nodes with the table of the last search, got %d after %d", nodes, firstNodes)
+	}
+
+	// After forgetting everything the search is the same as the first one
+	searcher.NewGame()
+	searcher.Search(p, limits)
+	if nodes != firstNodes {
+		t.Errorf("Expected %d nodes after a new game, got %d", firstNodes, nodes)
+	}
+}
@@ -0,0 +1,221 @@
+package t
+
+import (
+	"bytes"
+	"endtner.dev/nChess/internal/board"
+	"endtner.dev/nChess/internal/engine"
+	"endtner.dev/nChess/internal/uci"
+	"endtner.dev/nChess/internal/utils"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseGoLimits(t *testing.T) {
+	p := utils.FromFen(utils.StartPosition)
+	e2e4, _ := findLegalMove(p, "e2e4")
+	d2d4, _ := findLegalMove(p, "d2d4")
+
+	tests := []struct {
+		args     string
+		expected engine.SearchLimits
+	}{
+		{"wtime 60000 btime 30000 winc 1000 binc 500 movestogo 20", engine.SearchLimits{
+			WhiteTime: time.Minute, BlackTime: 30 * time.Second, WhiteIncrement: time.Second, BlackIncrement: 500 * time.Millisecond, MovesToGo: 20,
+		}},
+		{"depth 8 nodes 100000", engine.SearchLimits{Depth: 8, Nodes: 100000}},
+		{"ponder movetime 250", engine.SearchLimits{Ponder: true, MoveTime: 250 * time.Millisecond}},
+		{"mate 3", engine.SearchLimits{Mate: 3}},
+		{"searchmoves e2e4 d2d4 infinite", engine.SearchLimits{SearchMoves: []board.Move{e2e4, d2d4}, Infinite: true}},
+	}
+
+	for _, test := range tests {
+		limits, err := uci.ParseGoLimits(p, strings.Fields(test.args))
+		if err != nil || !reflect.DeepEqual(limits, test.expected) {
+			t.Errorf("[%s] Expected %+v, got %+v (%v)", test.args, test.expected, limits, err)
+		}
+	}
+
+	for _, args := range []string{"wtime", "btime 1000 movestogo", "wtime abc", "depth 3 searchmoves e2e5", "speed 3"} {
+		if _, err := uci.ParseGoLimits(p, strings.Fields(args)); err == nil {
+			t.Errorf("[%s] Expected an error", args)
+		}
+	}
+}
+
+func TestTimeManagerBudgets(t *testing.T) {
+	const overhead = 30 * time.Millisecond
+
+	tests := []struct {
+		name        string
+		limits      engine.SearchLimits
+		whiteToMove bool
+		soft, hard  time.Duration
+		limited     bool
+	}{
+		// A 30th of the time left plus three quarters of the increment, the hard limit is four times as much
+		{"Clock with increment", engine.SearchLimits{WhiteTime: time.Minute, BlackTime: time.Second, WhiteIncrement: time.Second}, true,
+			2749 * time.Millisecond, 10996 * time.Millisecond, true},
+		// With little time left the hard limit keeps a fifth of it in reserve
+		{"Moves to go", engine.SearchLimits{WhiteTime: time.Minute, BlackTime: time.Second, MovesToGo: 5}, false,
+			194 * time.Millisecond, 776 * time.Millisecond, true},
+		{"Move time", engine.SearchLimits{MoveTime: 500 * time.Millisecond}, true, 470 * time.Millisecond, 470 * time.Millisecond, true},
+		{"Move time below the overhead", engine.SearchLimits{MoveTime: 10 * time.Millisecond}, true, 0, 0, true},
+		{"Depth only", engine.SearchLimits{Depth: 5}, true, 0, 0, false},
+		{"Infinite", engine.SearchLimits{Infinite: true, WhiteTime: time.Minute}, true, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		tm := engine.NewTimeManager(test.limits, test.whiteToMove, overhead)
+		soft, hard, limited := tm.Budgets()
+		if soft != test.soft || hard != test.hard || limited != test.limited {
+			t.Errorf("[%s] Expected soft %v, hard %v, limited %t, got %v, %v, %t", test.name, test.soft, test.hard, test.limited, soft, hard, limited)
+		}
+	}
+}
+
+func TestBestMoveWithoutLegalMoves(t *testing.T) {
+	var output bytes.Buffer
+	e := uci.NewUCIEngine()
+	e.Output = &output
+
+	// Stalemate, stop waits until the bestmove was sent
+	for _, command := range []string{"position fen 7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", "go depth 3", "stop"} {
+		if err := e.HandleCommand(command); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !strings.Contains(output.String(), "bestmove 0000\n") {
+		t.Errorf("Expected bestmove 0000, got:\n%s", output.String())
+	}
+}
+
+// bestMoveWriter collects the output of the engine and signals every bestmove
+type bestMoveWriter struct {
+	mutex    sync.Mutex
+	output   bytes.Buffer
+	bestMove chan struct{}
+}
+
+func (w *bestMoveWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if bytes.HasPrefix(b, []byte("bestmove")) {
+		w.bestMove <- struct{}{}
+	}
+	return w.output.Write(b)
+}
+
+// runCommands sends the commands to a fresh engine, waiting for the bestmove after every go command
+func runCommands(t *testing.T, commands ...string) string {
+	w := &bestMoveWriter{bestMove: make(chan struct{}, 1)}
+	e := uci.NewUCIEngine()
+	e.Output = w
+
+	for _, command := range commands {
+		if err := e.HandleCommand(command); err != nil {
+			t.Fatalf("[%s] %v", command, err)
+		}
+
+		if strings.HasPrefix(command, "go") {
+			select {
+			case <-w.bestMove:
+			case <-time.After(10 * time.Second):
+				t.Fatalf("[%s] No bestmove within 10s", command)
+			}
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.output.String()
+}
+
+func TestSetOption(t *testing.T) {
+	tests := []struct {
+		command string
+		valid   bool
+	}{
+		{"setoption name Hash value 16", true},
+		{"setoption name hash value 16", true},
+		{"setoption name Move Overhead value 50", true},
+		{"setoption name Clear Hash", true},
+		{"setoption name OwnBook value true", true},
+		{"setoption name BookFile value <empty>", true},
+		{"setoption name Hash value 999999", false},
+		{"setoption name Hash value 0", false},
+		{"setoption name Threads value abc", false},
+		{"setoption name Threads value", false},
+		{"setoption name OwnBook value maybe", false},
+		{"setoption name Contempt value 10", false},
+		{"setoption name Clear", false},
+		{"setoption name", false},
+		{"setoption value 16", false},
+	}
+
+	e := uci.NewUCIEngine()
+	for _, test := range tests {
+		err := e.HandleCommand(test.command)
+		if test.valid && err != nil {
+			t.Errorf("[%s] Unexpected error: %v", test.command, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("[%s] Expected an error", test.command)
+		}
+	}
+}
+
+func TestRejectedOptionKeepsValue(t *testing.T) {
+	w := &bestMoveWriter{bestMove: make(chan struct{}, 1)}
+	e := uci.NewUCIEngine()
+	e.Output = w
+
+	if err := e.HandleCommand("setoption name MultiPV value 3"); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{"setoption name MultiPV value 999", "setoption name MultiPV value abc", "setoption name MultiPV value 0"} {
+		if err := e.HandleCommand(command); err == nil {
+			t.Errorf("[%s] Expected an error", command)
+		}
+	}
+	for _, command := range []string{"position startpos", "go depth 2"} {
+		if err := e.HandleCommand(command); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-w.bestMove
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if output := w.output.String(); !strings.Contains(output, "info multipv 3 ") || strings.Contains(output, "info multipv 4 ") {
+		t.Errorf("Expected three principal variations, got:\n%s", output)
+	}
+}
+
+// lastNodes returns the node count of the last info line
+func lastNodes(output string) int {
+	nodes := 0
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if i := slices.Index(fields, "nodes"); i != -1 && i+1 < len(fields) {
+			nodes, _ = strconv.Atoi(fields[i+1])
+		}
+	}
+	return nodes
+}
+
+func TestClearHashOption(t *testing.T) {
+	search := []string{"position startpos moves e2e4 e7e5", "go depth 6"}
+	kept := lastNodes(runCommands(t, append(slices.Clone(search), search...)...))
+	cleared := lastNodes(runCommands(t, append(append(slices.Clone(search), "setoption name Clear Hash"), search...)...))
+
+	// The history tables survive, but without the hash the search has to redo most of its work
+	if cleared <= kept {
+		t.Errorf("Expected more than %d nodes after clearing the hash, got %d", kept, cleared)
+	}
+}